@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -22,6 +24,7 @@ func main() {
 	blobs := flag.Bool("blobs", false, "process blobs")
 	flast := flag.Bool("lastmod", false, "track with lastmod")
 	dest := flag.String("dest", ".", "base destination directory")
+	failures := flag.String("failures", "blobfailures.json", "blob failures filename, in the destination directory")
 	flag.Parse()
 
 	if *q {
@@ -36,6 +39,11 @@ func main() {
 		log.SetOutput(f)
 	}
 
+	if flag.Arg(0) == "verify" {
+		verify(flag.Arg(1))
+		return
+	}
+
 	cfg, err := conf.LoadConfig(*c)
 	if err != nil {
 		log.Fatal("couldn't load config. ", err)
@@ -57,8 +65,10 @@ func main() {
 	}
 
 	lang := data.Language(settings.FilterLanguage)
+	ctx := context.Background()
+	src := process.NewSqlSource(cfg.ConnectionString)
 
-	pkg, err := process.ReadAll(cfg.ConnectionString, cfg.ProtobufLocation, psettings, lang, since)
+	pkg, err := process.ReadAll(ctx, src, cfg.ProtobufLocation, psettings, lang, since)
 	if err != nil || pkg == nil {
 		log.Fatal("reading items. ", err)
 	}
@@ -69,27 +79,84 @@ func main() {
 	}
 
 	contentLoc := filepath.Join(*dest, settings.Output.ContentLocation)
-	blobLoc := filepath.Join(*dest, settings.Output.ContentLocation)
+	blobLoc := filepath.Join(*dest, settings.Output.BlobLocation)
 
 	ws := process.WriteSettings{
 		ContentFormat:   settings.Output.ContentFormat,
 		ContentLocation: contentLoc,
 		BlobLocation:    blobLoc,
+		MediaRoot:       cfg.MediaRoot,
 		WriteBlobs:      *blobs,
+		Layout:          settings.Output.Layout,
+		FileNaming:      settings.Output.FileNaming,
+		BlobFormat:      settings.Output.BlobFormat,
+		BlobMetadata:    settings.Output.BlobMetadata,
+		BlobLayout:      settings.Output.BlobLayout,
+		BlobReaders:     settings.Output.BlobReaders,
+		BlobWriters:     settings.Output.BlobWriters,
+		Retry:           psettings.Retry,
+		Renditions:      psettings.BlobSettings.Renditions,
+		MaxPixels:       psettings.BlobSettings.MaxPixels,
+		BlobMaxSize:     psettings.BlobSettings.Filter.MaxSize,
 	}
 
+	blobsFailed := false
 	if ws.WriteBlobs {
-		log.Println("processing blobs in parallel")
-		process.ProcessBlobs(cfg.ConnectionString, groups, ws)
+		log.Println("processing blobs")
+		res := process.ProcessBlobs(ctx, src, groups, ws)
+		ws.BlobHashes = res.Hashes
+		ws.BlobSkipped = res.Reasons
+
+		fmt.Printf("blobs written: %d skipped: %d filtered: %d failed: %d\n", res.Written, res.Skipped, res.Filtered, res.Failed)
+		fpath := filepath.Join(*dest, *failures)
+		if res.Failed == 0 {
+			// a file from an earlier run would look like this one failed
+			if err := os.Remove(fpath); err != nil && !os.IsNotExist(err) {
+				log.Println("couldn't remove old blob failures.", err)
+			}
+		} else {
+			blobsFailed = true
+			err = process.WriteBlobFailures(fpath, res)
+			if err != nil {
+				log.Println("couldn't write blob failures.", err)
+			} else {
+				fmt.Println("blob failures written to", fpath)
+			}
+		}
 	}
 	err = process.WriteContent(groups, ws)
 	if err != nil {
 		log.Fatal("writing contents. ", err)
 	}
 
-	if *flast {
+	// failed blobs need to be picked up again next time
+	if *flast && !blobsFailed {
 		process.WriteLastMod(*es)
 	}
 
 	log.Println("Time:", time.Since(start))
+
+	if blobsFailed {
+		os.Exit(1)
+	}
+}
+
+// scexport verify <dir>
+func verify(dir string) {
+	if dir == "" {
+		log.Fatal("usage: scexport verify <dir>")
+	}
+
+	res, err := process.Verify(dir)
+	if err != nil {
+		log.Fatal("verifying. ", err)
+	}
+
+	for _, p := range res.Problems {
+		fmt.Println(p.File, p.BlobId, p.Reason)
+	}
+	fmt.Printf("blobs checked: %d referenced: %d problems: %d\n", res.Checked, res.Referenced, len(res.Problems))
+	if len(res.Problems) > 0 {
+		os.Exit(1)
+	}
 }
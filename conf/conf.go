@@ -5,24 +5,62 @@ import "github.com/jasontconnell/conf"
 type Config struct {
 	ConnectionString string `json:"connectionString"`
 	ProtobufLocation string `json:"protobufLocation"`
+	MediaRoot        string `json:"mediaRoot"`
 }
 
 type WriteSettings struct {
 	ContentFormat   string `json:"contentFormat"`
 	ContentLocation string `json:"contentLocation"`
 	BlobLocation    string `json:"blobLocation"`
+	Layout          string `json:"layout"`
+	FileNaming      string `json:"fileNaming"`
+	BlobFormat      string `json:"blobFormat"`
+	BlobMetadata    string `json:"blobMetadata"`
+	BlobLayout      string `json:"blobLayout"`
+	BlobReaders     int    `json:"blobReaders"`
+	BlobWriters     int    `json:"blobWriters"`
 }
 
 type BlobSettings struct {
-	CustomFields []string `json:"customFields"`
+	CustomFields []string            `json:"customFields"`
+	Renditions   []RenditionSettings `json:"renditions"`
+	MaxPixels    int                 `json:"maxPixels"`
+
+	MaxSize         string   `json:"maxSize"`
+	AllowExtensions []string `json:"allowExtensions"`
+	DenyExtensions  []string `json:"denyExtensions"`
+	AllowMimeTypes  []string `json:"allowMimeTypes"`
+	DenyMimeTypes   []string `json:"denyMimeTypes"`
+	IncludePaths    []string `json:"includePaths"`
+	ExcludePaths    []string `json:"excludePaths"`
+}
+
+type RenditionSettings struct {
+	Name      string `json:"name"`
+	MaxWidth  int    `json:"maxWidth"`
+	MaxHeight int    `json:"maxHeight"`
+	Format    string `json:"format"`
+	Quality   int    `json:"quality"`
+}
+
+type RetrySettings struct {
+	Attempts int    `json:"attempts"`
+	Delay    string `json:"delay"`
+	MaxDelay string `json:"maxDelay"`
+	Timeout  string `json:"timeout"`
+
+	MaxAbandoned int `json:"maxAbandoned"`
 }
 
 type ExportSettings struct {
 	FilterLanguage     string           `json:"filterLanguage"`
 	Templates          []ExportTemplate `json:"templates"`
 	ReferenceTemplates []ExportTemplate `json:"referenceTemplates"`
-	BlobSettings       BlobSettings     `json:"blobSettings"`
-	Output             WriteSettings    `json:"output"`
+
+	DatasourceTemplates []ExportTemplate `json:"datasourceTemplates"`
+	BlobSettings        BlobSettings     `json:"blobSettings"`
+	Output              WriteSettings    `json:"output"`
+	Retry               RetrySettings    `json:"retry"`
 }
 
 type ExportTemplate struct {
@@ -30,12 +68,25 @@ type ExportTemplate struct {
 	TemplateId string        `json:"templateId"`
 	Paths      []string      `json:"paths"`
 	Fields     []ExportField `json:"fields"`
+
+	FollowDatasources bool `json:"followDatasources"`
 }
 
 type ExportField struct {
-	Name     string `json:"name"`
-	Alias    string `json:"alias"`
-	RefField string `json:"refField"`
+	Name       string              `json:"name"`
+	Alias      string              `json:"alias"`
+	RefField   string              `json:"refField"`
+	Source     string              `json:"source"`
+	Format     string              `json:"format"`
+	Transforms []TransformSettings `json:"transforms"`
+}
+
+type TransformSettings struct {
+	Name       string   `json:"name"`
+	Tags       []string `json:"tags"`
+	Attributes []string `json:"attributes"`
+	Find       string   `json:"find"`
+	Replace    string   `json:"replace"`
 }
 
 func LoadConfig(fn string) (Config, error) {
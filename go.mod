@@ -6,6 +6,8 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/jasontconnell/conf v1.1.1
 	github.com/jasontconnell/sitecore v1.6.8
+	golang.org/x/image v0.18.0
+	golang.org/x/net v0.33.0
 )
 
 require (
@@ -13,6 +15,6 @@ require (
 	github.com/golang-sql/sqlexp v0.1.0 // indirect
 	github.com/jasontconnell/sqlhelp v1.0.0 // indirect
 	github.com/microsoft/go-mssqldb v0.21.0 // indirect
-	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
+	golang.org/x/crypto v0.31.0 // indirect
 	google.golang.org/protobuf v1.26.0 // indirect
 )
@@ -0,0 +1,163 @@
+package process
+
+import (
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jasontconnell/scexport/conf"
+)
+
+var sizeUnits map[string]int = map[string]int{
+	"":   1,
+	"b":  1,
+	"kb": 1 << 10,
+	"mb": 1 << 20,
+	"gb": 1 << 30,
+}
+
+func getBlobFilter(cfg conf.BlobSettings) (BlobFilter, error) {
+	f := BlobFilter{
+		AllowExtensions: getFilterExtensions(cfg.AllowExtensions),
+		DenyExtensions:  getFilterExtensions(cfg.DenyExtensions),
+		AllowMimeTypes:  getFilterLower(cfg.AllowMimeTypes),
+		DenyMimeTypes:   getFilterLower(cfg.DenyMimeTypes),
+		IncludePaths:    getFilterPaths(cfg.IncludePaths),
+		ExcludePaths:    getFilterPaths(cfg.ExcludePaths),
+	}
+
+	if cfg.MaxSize != "" {
+		size, err := parseSize(cfg.MaxSize)
+		if err != nil {
+			return f, err
+		}
+		f.MaxSize = size
+	}
+	return f, nil
+}
+
+// 500, 500kb, 1.5mb or 2gb
+func parseSize(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	num, unit := s, ""
+	if i != -1 {
+		num, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+
+	mult, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %s in %s", unit, s)
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("couldn't parse size %s", s)
+	}
+	return int(f * float64(mult)), nil
+}
+
+func getFilterExtensions(list []string) []string {
+	exts := []string{}
+	for _, e := range getFilterLower(list) {
+		exts = append(exts, strings.TrimPrefix(e, "."))
+	}
+	return exts
+}
+
+func getFilterLower(list []string) []string {
+	lower := []string{}
+	for _, s := range list {
+		if s = strings.ToLower(strings.TrimSpace(s)); s != "" {
+			lower = append(lower, s)
+		}
+	}
+	return lower
+}
+
+func getFilterPaths(list []string) []string {
+	paths := []string{}
+	for _, p := range getFilterLower(list) {
+		paths = append(paths, strings.TrimSuffix(p, "/"))
+	}
+	return paths
+}
+
+// an empty reason means the blob is exported. the size is only known here
+// when the media item has it, otherwise it's checked once the blob is read
+func getBlobSkipReason(b Blob, f BlobFilter) string {
+	if f.MaxSize > 0 && b.Size > f.MaxSize {
+		return getSizeSkipReason(b.Size, f.MaxSize)
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(b.Filename), "."))
+	if len(f.AllowExtensions) > 0 && !containsString(f.AllowExtensions, ext) {
+		return fmt.Sprintf("extension %s is not allowed", ext)
+	}
+	if containsString(f.DenyExtensions, ext) {
+		return fmt.Sprintf("extension %s is denied", ext)
+	}
+
+	mt := stripMimeParams(b.MimeType)
+	if mt == "" {
+		mt = stripMimeParams(mime.TypeByExtension("." + ext))
+	}
+	if len(f.AllowMimeTypes) > 0 && mt == "" {
+		return "mime type is unknown"
+	}
+	if len(f.AllowMimeTypes) > 0 && !matchMimeType(f.AllowMimeTypes, mt) {
+		return fmt.Sprintf("mime type %s is not allowed", mt)
+	}
+	if matchMimeType(f.DenyMimeTypes, mt) {
+		return fmt.Sprintf("mime type %s is denied", mt)
+	}
+
+	if len(f.IncludePaths) > 0 && !matchPath(f.IncludePaths, b.Path) {
+		return "path is not under an included path"
+	}
+	if p := getMatchedPath(f.ExcludePaths, b.Path); p != "" {
+		return fmt.Sprintf("path is under excluded path %s", p)
+	}
+	return ""
+}
+
+func getSizeSkipReason(size, max int) string {
+	return fmt.Sprintf("size %d is over the max size %d", size, max)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// image/* matches any image
+func matchMimeType(list []string, mt string) bool {
+	if mt == "" {
+		return false
+	}
+	for _, v := range list {
+		if v == mt || (strings.HasSuffix(v, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(v, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(list []string, p string) bool {
+	return getMatchedPath(list, p) != ""
+}
+
+func getMatchedPath(list []string, p string) string {
+	lower := strings.ToLower(p)
+	for _, v := range list {
+		if lower == v || strings.HasPrefix(lower, v+"/") {
+			return v
+		}
+	}
+	return ""
+}
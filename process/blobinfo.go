@@ -0,0 +1,153 @@
+package process
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+const svgMimeType string = "image/svg+xml"
+
+// what the blob bytes actually are, the media item's mime type and extension
+// are whatever was uploaded or typed in
+type blobInfo struct {
+	ContentType string
+	Width       int
+	Height      int
+	Mismatch    string
+}
+
+func getBlobInfo(data []byte, filename string) blobInfo {
+	info := blobInfo{ContentType: sniffContentType(data)}
+
+	if info.ContentType == svgMimeType {
+		info.Width, info.Height = getSvgSize(data)
+	} else if strings.HasPrefix(info.ContentType, "image/") {
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			info.Width, info.Height = cfg.Width, cfg.Height
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	expected := stripMimeParams(mime.TypeByExtension(ext))
+	if expected != "" && !isUnknownContentType(info.ContentType, expected) && !sameContentType(expected, info.ContentType) {
+		info.Mismatch = fmt.Sprintf("extension %s is %s but the content is %s", ext, expected, info.ContentType)
+	}
+	return info
+}
+
+func sniffContentType(data []byte) string {
+	ct := stripMimeParams(http.DetectContentType(data))
+	// svg sniffs as xml or text
+	if strings.HasPrefix(ct, "text/") && isSvg(data) {
+		return svgMimeType
+	}
+	return ct
+}
+
+func isSvg(data []byte) bool {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return strings.EqualFold(se.Name.Local, "svg")
+		}
+	}
+}
+
+// width and height on the svg element, or the view box when they're
+// missing or in units that aren't pixels
+func getSvgSize(data []byte) (int, int) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		var w, h int
+		var vb []string
+		for _, a := range se.Attr {
+			switch strings.ToLower(a.Name.Local) {
+			case "width":
+				w = parseSvgLength(a.Value)
+			case "height":
+				h = parseSvgLength(a.Value)
+			case "viewbox":
+				vb = strings.FieldsFunc(a.Value, func(r rune) bool { return r == ' ' || r == ',' })
+			}
+		}
+		if (w == 0 || h == 0) && len(vb) == 4 {
+			vw, _ := strconv.ParseFloat(vb[2], 64)
+			vh, _ := strconv.ParseFloat(vb[3], 64)
+			w, h = int(vw+0.5), int(vh+0.5)
+		}
+		return w, h
+	}
+}
+
+func parseSvgLength(s string) int {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "px")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0
+	}
+	return int(f + 0.5)
+}
+
+func stripMimeParams(s string) string {
+	if i := strings.Index(s, ";"); i != -1 {
+		s = s[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+func isGenericContentType(ct string) bool {
+	return ct == "" || ct == "application/octet-stream" || ct == "text/plain" || ct == "text/xml"
+}
+
+// sniffing only knows so many types, anything it can't tell isn't a mismatch.
+// text is only known to be wrong when it should have been an image, and
+// office documents are zips
+func isUnknownContentType(ct, expected string) bool {
+	if ct == "" || ct == "application/octet-stream" || ct == "application/zip" {
+		return true
+	}
+	return (ct == "text/plain" || ct == "text/xml") && !strings.HasPrefix(expected, "image/")
+}
+
+func sameContentType(a, b string) bool {
+	if a == b {
+		return true
+	}
+	// x- prefixed and older names for the same thing
+	alias := map[string]string{"image/jpg": "image/jpeg", "image/pjpeg": "image/jpeg", "application/x-pdf": "application/pdf", "image/x-icon": "image/vnd.microsoft.icon"}
+	if v, ok := alias[a]; ok {
+		a = v
+	}
+	if v, ok := alias[b]; ok {
+		b = v
+	}
+	return a == b
+}
@@ -1,139 +1,479 @@
 package process
 
 import (
+	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"math"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 
-	"github.com/jasontconnell/sitecore/api"
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
 )
 
-const parallelWriteProcesses int = 8
+const (
+	defaultBlobReaders int = 4
+	defaultBlobWriters int = 4
+)
+
+const (
+	blobWritten int = iota
+	blobSkipped
+	blobFailed
+	blobFiltered
+)
+
+type BlobsResult struct {
+	Written  int
+	Skipped  int
+	Failed   int
+	Filtered int
+	Errors   []BlobError
+	Hashes   map[uuid.UUID]string
+	Reasons  map[uuid.UUID]string
+}
+
+type BlobError struct {
+	BlobId   uuid.UUID
+	ItemId   uuid.UUID
+	Filename string
+	Path     string
+	Err      error
+}
+
+func (e BlobError) Error() string {
+	return fmt.Sprintf("blob %v (item %v, %s) %v", e.BlobId, e.ItemId, e.Path, e.Err)
+}
 
-func ProcessBlobs(connstr string, groups []Group, ws WriteSettings) {
+type blobStatus struct {
+	state  int
+	err    BlobError
+	blobId uuid.UUID
+	reason string
+}
+
+func blobFailure(blobId, itemId uuid.UUID, filename, path string, err error) blobStatus {
+	return blobStatus{state: blobFailed, err: BlobError{BlobId: blobId, ItemId: itemId, Filename: filename, Path: path, Err: err}}
+}
+
+// hashes are returned when blobs are stored by hash, so the content can
+// point to them. so are the reasons for blobs that were only found to be
+// too big once they were read
+func ProcessBlobs(ctx context.Context, src Source, groups []Group, ws WriteSettings) BlobsResult {
+	result := BlobsResult{Reasons: make(map[uuid.UUID]string)}
 	allblobs := []Blob{}
-	dedup := make(map[string]bool)
+	dedup := make(map[uuid.UUID]bool)
+	files := make(map[string]Blob)
 	for _, g := range groups {
 		for _, b := range g.Blobs {
-			if _, ok := dedup[b.Filename]; ok {
+			if dedup[b.BlobId] {
 				continue
 			}
+			dedup[b.BlobId] = true
+
+			if b.SkipReason != "" {
+				log.Println("blob", b.BlobId, b.Path, "filtered.", b.SkipReason)
+				result.Filtered++
+				continue
+			}
+
+			// hashed blobs don't know their file until they're read
+			b.File = getBlobFile(b, ws.BlobLayout)
+			if b.File == "" {
+				allblobs = append(allblobs, b)
+				continue
+			}
+
+			// renditions are checked too, hero.jpg.thumb.png could be a blob. a
+			// collision is a failure, content would point at the other blob's file
+			paths := append([]string{b.File}, getRenditionFiles(ws.Renditions, b.File)...)
+			if other, p, ok := getFileCollision(files, paths); ok {
+				s := blobFailure(b.BlobId, b.ItemId, b.Filename, b.Path, fmt.Errorf("collides with blob %v (item %v) on %s", other.BlobId, other.ItemId, p))
+				result.Failed++
+				result.Errors = append(result.Errors, s.err)
+				log.Println("blob collision.", s.err.Error())
+				continue
+			}
+			for _, p := range paths {
+				files[strings.ToLower(p)] = b
+			}
 			allblobs = append(allblobs, b)
-			dedup[b.Filename] = true
 		}
 	}
 
 	if len(allblobs) == 0 {
 		log.Println("no blobs to process")
-		return
+		return result
+	}
+
+	manifest, err := readBlobManifest(ws.BlobLocation)
+	if err != nil {
+		log.Println("couldn't read blob manifest, all blobs will be written.", err)
+		manifest = newBlobManifest()
 	}
 
-	bchan := make(chan BlobData, 50000)
-	echan := make(chan error, 50000)
+	if ws.BlobLayout != HashBlobLayout {
+		for _, b := range allblobs {
+			manifest.release(filepath.ToSlash(getBlobOutputFile(ws, b.File)), b.BlobId)
+			for _, f := range getRenditionFiles(ws.Renditions, b.File) {
+				manifest.release(filepath.ToSlash(getBlobOutputFile(ws, f)), b.BlobId)
+			}
+		}
+	}
 
-	go errListener(echan)
-	var wg sync.WaitGroup
+	readers, writers := ws.BlobReaders, ws.BlobWriters
+	if readers < 1 {
+		readers = defaultBlobReaders
+	}
+	if writers < 1 {
+		writers = defaultBlobWriters
+	}
 
-	log.Println("reading", len(allblobs), "blobs")
-	if len(allblobs) > 100 {
-		size := len(allblobs) / parallelWriteProcesses
-		batches := int(math.Ceil(float64(len(allblobs)) / float64(size)))
+	// only as many blobs are held in memory as there are readers and writers,
+	// plus what fits in the channel between them
+	jobs := make(chan Blob)
+	bchan := make(chan BlobData, writers)
+	schan := make(chan blobStatus, readers+writers)
 
-		log.Println("processing", batches, "batches of", size, "blobs each")
+	var swg sync.WaitGroup
+	swg.Add(1)
+	go func() {
+		collectBlobStatus(schan, &result)
+		swg.Done()
+	}()
 
-		wg.Add(batches)
-		for i := 0; i < batches; i++ {
-			go func(s, idx int) {
-				start := s * idx
-				end := idx*s + s
-				if end >= len(allblobs) {
-					end = len(allblobs) - 1
-				}
-				batch := allblobs[start:end]
-				readBlobs(connstr, batch, ws, bchan, echan)
-				wg.Done()
-			}(size, i)
-		}
-		wg.Wait()
-	} else {
-		readBlobs(connstr, allblobs, ws, bchan, echan)
+	log.Println("processing", len(allblobs), "blobs with", readers, "readers and", writers, "writers")
+	var rwg, wwg sync.WaitGroup
+	rwg.Add(readers)
+	for i := 0; i < readers; i++ {
+		go func() {
+			readBlobs(ctx, src, jobs, ws, manifest, bchan, schan)
+			rwg.Done()
+		}()
 	}
 
-	log.Println("writing", len(bchan), "blobs")
-	wg.Add(parallelWriteProcesses)
-	for i := 0; i < parallelWriteProcesses; i++ {
+	wwg.Add(writers)
+	for i := 0; i < writers; i++ {
 		go func() {
-			writeBlobs(ws, bchan, echan)
-			wg.Done()
+			writeBlobs(ws, manifest, bchan, schan)
+			wwg.Done()
 		}()
 	}
 
-	wg.Wait()
+	for _, b := range allblobs {
+		jobs <- b
+	}
+	close(jobs)
+
+	// writers finish what's queued once the readers are done
+	rwg.Wait()
+	close(bchan)
+	wwg.Wait()
+	close(schan)
+	swg.Wait()
+
+	err = manifest.write(ws.BlobLocation)
+	if err != nil {
+		log.Println("couldn't write blob manifest.", err)
+	}
+
+	if ws.BlobLayout == HashBlobLayout {
+		result.Hashes = manifest.hashes()
+	}
+	return result
 }
 
-func errListener(echan chan error) {
-	for {
-		select {
-		case err := <-echan:
-			log.Println("error occurred in process blobs", err.Error())
+func collectBlobStatus(schan chan blobStatus, result *BlobsResult) {
+	for s := range schan {
+		switch s.state {
+		case blobWritten:
+			result.Written++
+		case blobSkipped:
+			result.Skipped++
+		case blobFiltered:
+			result.Filtered++
+			result.Reasons[s.blobId] = s.reason
+			log.Println("blob", s.blobId, "filtered.", s.reason)
+		case blobFailed:
+			result.Failed++
+			result.Errors = append(result.Errors, s.err)
+			log.Println("error occurred in process blobs", s.err.Error())
 		}
 	}
 }
 
-func readBlobs(connstr string, blobs []Blob, ws WriteSettings, blobchan chan BlobData, echan chan error) {
-	existing := make(map[string]bool)
-	files, _ := os.ReadDir(ws.BlobLocation)
-	for _, f := range files {
-		nm := strings.TrimSuffix(f.Name(), "."+ws.ContentFormat)
-		existing[nm] = true
+type blobFailureJson struct {
+	BlobId   string `json:"blobId"`
+	ItemId   string `json:"itemId"`
+	Filename string `json:"filename"`
+	Path     string `json:"path"`
+	Error    string `json:"error"`
+}
+
+func WriteBlobFailures(path string, result BlobsResult) error {
+	list := []blobFailureJson{}
+	for _, e := range result.Errors {
+		list = append(list, blobFailureJson{BlobId: e.BlobId.String(), ItemId: e.ItemId.String(), Filename: e.Filename, Path: e.Path, Error: e.Err.Error()})
 	}
 
-	for _, b := range blobs {
-		if _, ok := existing[b.Filename]; ok {
-			continue
+	buf, err := json.MarshalIndent(list, "", " ")
+	if err != nil {
+		return fmt.Errorf("encoding blob failures. %w", err)
+	}
+
+	err = os.WriteFile(path, buf, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("writing blob failures %s. %w", path, err)
+	}
+	return nil
+}
+
+func readBlobs(ctx context.Context, src Source, jobs chan Blob, ws WriteSettings, manifest *blobManifest, blobchan chan BlobData, schan chan blobStatus) {
+	for b := range jobs {
+		// hashed files aren't known until the blob is read, the manifest
+		// only has files that are still there
+		// media items have their size, when it's not the length that was
+		// written last time the blob changed. without a size there's no
+		// telling, so it's written again. so is everything when the
+		// renditions change
+		if mb, ok := manifest.get(b.BlobId); ok && mb.Format == getBlobFormat(ws) && b.Size > 0 && b.Size == mb.Length && mb.RenditionsHash == getRenditionsHash(ws.Renditions) {
+			if ws.BlobLayout == HashBlobLayout || mb.File == filepath.ToSlash(getBlobOutputFile(ws, b.File)) {
+				schan <- blobStatus{state: blobSkipped}
+				continue
+			}
 		}
 
-		blob, err := api.LoadBlob(connstr, b.BlobId)
+		var blob data.Blob
+		var err error
+		if b.FilePath != "" {
+			blob, err = readMediaFile(ws.MediaRoot, b.BlobId, b.FilePath)
+		} else {
+			blob, err = withRetry(ctx, ws.Retry, "loading blob "+b.BlobId.String(), func() (data.Blob, error) {
+				return src.LoadBlob(b.BlobId)
+			})
+		}
 		if err != nil {
-			echan <- fmt.Errorf("couldn't load blob %v %w", b.BlobId, err)
+			schan <- blobFailure(b.BlobId, b.ItemId, b.Filename, b.Path, fmt.Errorf("couldn't load blob. %w", err))
+			continue
+		}
+		if len(blob.GetData()) == 0 {
+			schan <- blobFailure(b.BlobId, b.ItemId, b.Filename, b.Path, fmt.Errorf("no blob data"))
+			continue
+		}
+		if ws.BlobMaxSize > 0 && len(blob.GetData()) > ws.BlobMaxSize {
+			schan <- blobStatus{state: blobFiltered, blobId: b.BlobId, reason: getSizeSkipReason(len(blob.GetData()), ws.BlobMaxSize)}
+			continue
 		}
 
-		bdata := BlobData{ItemId: b.ItemId, BlobId: b.BlobId, Path: b.Path, Data: blob.GetData(), Attrs: b.Attrs, Filename: b.Filename}
+		bdata := BlobData{ItemId: b.ItemId, BlobId: b.BlobId, Path: b.Path, Data: blob.GetData(), Attrs: b.Attrs, Filename: b.Filename, MimeType: b.MimeType, File: b.File, Language: b.Language, FilePath: b.FilePath}
 		blobchan <- bdata
 	}
 }
 
-func writeBlobs(settings WriteSettings, bchan chan BlobData, echan chan error) {
-	fulldir := settings.BlobLocation
-	err := os.MkdirAll(fulldir, os.ModePerm)
+func writeBlobs(settings WriteSettings, manifest *blobManifest, bchan chan BlobData, schan chan blobStatus) {
+	isxml := settings.ContentFormat == "xml"
+	for b := range bchan {
+		if settings.BlobFormat != RawBlobFormat && !isxml {
+			schan <- blobStatus{state: blobSkipped}
+			continue
+		}
+
+		sum := sha256.Sum256(b.Data)
+		hash := hex.EncodeToString(sum[:])
+		if settings.BlobLayout == HashBlobLayout {
+			b.File = getHashFile(hash, b.Filename)
+		}
+
+		file := getBlobOutputFile(settings, b.File)
+		mb := ManifestBlobJson{BlobId: b.BlobId.String(), ItemId: b.ItemId.String(), Filename: b.Filename, Language: b.Language, File: filepath.ToSlash(file), Format: getBlobFormat(settings), Length: len(b.Data), Hash: hash}
+		if !manifest.add(b.BlobId, mb) && settings.BlobLayout == HashBlobLayout {
+			log.Println("blob", b.BlobId, "has the same content as", b.File)
+			schan <- blobStatus{state: blobSkipped}
+			continue
+		}
+
+		path := filepath.Join(settings.BlobLocation, file)
+		err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+		if err != nil {
+			manifest.remove(b.BlobId)
+			schan <- blobFailure(b.BlobId, b.ItemId, b.Filename, b.Path, fmt.Errorf("couldn't create dir structure %s. %w", filepath.Dir(path), err))
+			continue
+		}
+
+		info := getBlobInfo(b.Data, b.Filename)
+		if info.Mismatch != "" {
+			log.Printf("blob %v (item %v) %s %s\n", b.BlobId, b.ItemId, b.Path, info.Mismatch)
+		}
+
+		// a rendition that can't be made is logged, the original is still written
+		meta := getBlobXml(b, info)
+		rlist, mlist, rerr := writeRenditions(settings, b, info)
+		if rerr != nil {
+			log.Printf("blob %v (item %v) %s %v\n", b.BlobId, b.ItemId, b.Path, rerr)
+		}
+		meta.Renditions = rlist
+
+		if settings.BlobFormat == RawBlobFormat {
+			log.Println("writing blob", b.File)
+			err = writeBlobRaw(path, b, meta, settings.BlobMetadata)
+		} else {
+			log.Println("writing blob xml", b.File)
+			meta.Data = &BlobDataXml{Data: base64.StdEncoding.EncodeToString(b.Data)}
+			err = writeBlobXml(path, meta)
+		}
+
+		if err != nil {
+			manifest.remove(b.BlobId)
+			schan <- blobFailure(b.BlobId, b.ItemId, b.Filename, b.Path, fmt.Errorf("writing %s. %w", path, err))
+			continue
+		}
+
+		// renditions that couldn't be made are tried again next time
+		mb.Renditions = mlist
+		if rerr == nil {
+			mb.RenditionsHash = getRenditionsHash(settings.Renditions)
+		}
+		manifest.add(b.BlobId, mb)
+		schan <- blobStatus{state: blobWritten}
+	}
+}
+
+// file path media is relative to the site root, e.g. /App_Data/MediaFiles/...
+func readMediaFile(root string, id uuid.UUID, fp string) (data.Blob, error) {
+	if root == "" {
+		return nil, fmt.Errorf("media file %s needs mediaRoot in the config", fp)
+	}
+
+	rel := strings.TrimPrefix(strings.ReplaceAll(fp, "\\", "/"), "~")
+	full := filepath.Join(root, filepath.FromSlash(strings.TrimPrefix(rel, "/")))
+	if r, err := filepath.Rel(root, full); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("media file %s is outside the media root", fp)
+	}
+
+	buf, err := os.ReadFile(full)
 	if err != nil {
-		echan <- fmt.Errorf("couldn't create dir structure %s. %w", fulldir, err)
+		return nil, fmt.Errorf("reading media file. %w", err)
 	}
-	isxml := settings.ContentFormat == "xml"
-	done := false
-	for !done {
-		select {
-		case b := <-bchan:
-			log.Println("writing blob xml", b.Filename)
-			if isxml {
-				bfields := []BlobFieldXml{}
-				for _, f := range b.Attrs {
-					bfields = append(bfields, BlobFieldXml{Name: f.Name, Value: f.Value})
-				}
-				bxml := BlobXml{ItemId: b.ItemId.String(), BlobId: b.BlobId.String(), Path: b.Path, Filename: b.Filename, Length: len(b.Data), Fields: bfields, Data: BlobDataXml{Data: base64.StdEncoding.EncodeToString(b.Data)}}
-				path := filepath.Join(fulldir, bxml.Filename+".xml")
-				err = writeBlobXml(path, bxml)
-				if err != nil {
-					echan <- fmt.Errorf("writing file contents for %s, path: %s. %w", b.Filename, path, err)
-				}
-			}
-		default:
-			done = true
+	return data.NewBlob(id, buf), nil
+}
+
+// blobs go flat in the blob location by filename, or in folders under it
+// following the media library
+func getBlobFile(b Blob, layout string) string {
+	if layout == HashBlobLayout {
+		return ""
+	}
+	if layout != MediaBlobLayout {
+		return b.Filename
+	}
+
+	p := b.Path
+	if strings.HasPrefix(strings.ToLower(p), mediaLibraryPath) {
+		p = p[len(mediaLibraryPath):]
+	}
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) > 0 {
+		parts = parts[:len(parts)-1]
+	}
+	return filepath.Join(append(parts, b.Filename)...)
+}
+
+// split on the first two characters of the hash so no folder gets too big.
+// the extension stays so the files can still be opened
+func getHashFile(hash, filename string) string {
+	return filepath.Join(hash[:2], hash+strings.ToLower(filepath.Ext(filename)))
+}
+
+// relative to the blob location
+func getFileCollision(files map[string]Blob, paths []string) (Blob, string, bool) {
+	for _, p := range paths {
+		if b, ok := files[strings.ToLower(p)]; ok {
+			return b, p, true
+		}
+	}
+	return Blob{}, "", false
+}
+
+func getBlobOutputFile(settings WriteSettings, file string) string {
+	if settings.BlobFormat != RawBlobFormat {
+		return file + ".xml"
+	}
+	return file
+}
+
+func getBlobFormat(settings WriteSettings) string {
+	if settings.BlobFormat == RawBlobFormat {
+		return RawBlobFormat
+	}
+	return Base64BlobFormat
+}
+
+func getBlobXml(b BlobData, info blobInfo) BlobXml {
+	bfields := []BlobFieldXml{}
+	for _, f := range b.Attrs {
+		bfields = append(bfields, BlobFieldXml{Name: f.Name, Value: f.Value})
+	}
+
+	mimeType := b.MimeType
+	if mimeType == "" && !isGenericContentType(info.ContentType) {
+		mimeType = info.ContentType
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(filepath.Ext(b.Filename))
+	}
+
+	return BlobXml{
+		ItemId:      b.ItemId.String(),
+		BlobId:      b.BlobId.String(),
+		Path:        b.Path,
+		Filename:    b.Filename,
+		Length:      len(b.Data),
+		Language:    b.Language,
+		FilePath:    b.FilePath,
+		MimeType:    mimeType,
+		ContentType: info.ContentType,
+		Width:       info.Width,
+		Height:      info.Height,
+		Mismatch:    info.Mismatch,
+		Fields:      bfields,
+	}
+}
+
+// the original bytes so the file can be opened directly, with the rest of
+// the blob info in a sidecar next to it
+func writeBlobRaw(path string, b BlobData, meta BlobXml, metadata string) error {
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(b.Data)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing blob data. %w", err)
+	}
+
+	if metadata == JsonBlobMetadata {
+		path = path + ".json"
+		buf, err := json.MarshalIndent(meta, "", " ")
+		if err != nil {
+			return fmt.Errorf("encoding blob metadata %s. %w", b.Filename, err)
+		}
+		err = writeFileAtomic(path, func(w io.Writer) error {
+			_, err := w.Write(buf)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("writing blob metadata. %w", err)
 		}
+		return nil
 	}
+
+	return writeBlobXml(path+".xml", meta)
 }
@@ -5,4 +5,46 @@ const (
 	IdOutputField       string = ":id"
 	PathOutputField     string = ":path"
 	BlobOutputField     string = ":blob"
+	TemplateOutputField string = ":template"
+
+	DisplayNameOutputField string = ":displayname"
+	CreatedOutputField     string = ":created"
+	CreatedByOutputField   string = ":createdby"
+	UpdatedOutputField     string = ":updated"
+	UpdatedByOutputField   string = ":updatedby"
+	OwnerOutputField       string = ":owner"
+	SortOrderOutputField   string = ":sortorder"
+	RevisionOutputField    string = ":revision"
+	IconOutputField        string = ":icon"
+	HiddenOutputField      string = ":hidden"
+)
+
+const filePathFieldName string = "File Path"
+
+const (
+	fieldAltSource string = "field"
+	mediaAltSource string = "media"
+)
+
+const (
+	FlatLayout  string = "flat"
+	TreeLayout  string = "tree"
+	FilesLayout string = "files"
+)
+
+const (
+	Base64BlobFormat string = "base64"
+	RawBlobFormat    string = "raw"
+
+	XmlBlobMetadata  string = "xml"
+	JsonBlobMetadata string = "json"
+
+	FlatBlobLayout  string = "flat"
+	MediaBlobLayout string = "media"
+	HashBlobLayout  string = "hash"
+)
+
+const (
+	IdFileNaming   string = "id"
+	PathFileNaming string = "path"
 )
@@ -1,6 +1,9 @@
 package process
 
 import (
+	"regexp"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/jasontconnell/sitecore/data"
 )
@@ -11,28 +14,95 @@ type BlobData struct {
 	Data     []byte
 	Filename string
 	Path     string
+	MimeType string
+	File     string
+	Language string
+	FilePath string
 	Attrs    []Attr
 }
 
 type Group struct {
-	Name  string
-	Items []Item
-	Blobs []Blob
+	Name    string
+	Items   []Item
+	Blobs   []Blob
+	Folders []Item
 }
 
 type Item struct {
-	ID     string
-	Name   string
-	Path   string
-	Fields []Field
-	Blobs  []Blob
+	ID         string
+	Name       string
+	Path       string
+	Fields     []Field
+	Blobs      []Blob
+	ItemRefs   []ItemRef
+	Unresolved []Unresolved
+
+	TypeName    string
+	Datasources []Item
+
+	ParentId    string
+	SortOrder   string
+	Placeholder bool
+	Children    []Item
 }
 
 type Field struct {
-	Name  string
+	Name   string
+	Value  string
+	CData  bool
+	Refs   []Item
+	Link   *Link
+	Image  *Image
+	Layout *Layout
+}
+
+type Layout struct {
+	Devices []LayoutDevice
+}
+
+type LayoutDevice struct {
+	ID         string
+	Name       string
+	LayoutId   string
+	LayoutName string
+	Renderings []LayoutRendering
+}
+
+type LayoutRendering struct {
+	ID          string
+	Name        string
+	Uid         string
+	Placeholder string
+	DataSource  *DataSource
+	Parameters  []Attr
+}
+
+type DataSource struct {
 	Value string
-	CData bool
-	Refs  []Item
+	ID    string
+	Path  string
+}
+
+type Image struct {
+	Alt       string
+	AltSource string
+	Width     string
+	Height    string
+	HSpace    string
+	VSpace    string
+	Class     string
+}
+
+type Link struct {
+	Type        string
+	Url         string
+	Text        string
+	Title       string
+	Target      string
+	Anchor      string
+	QueryString string
+	Class       string
+	Item        *Item
 }
 
 type Blob struct {
@@ -41,6 +111,25 @@ type Blob struct {
 	Filename string
 	Attrs    []Attr
 	Path     string
+	MimeType string
+	File     string
+	Size     int
+	Language string
+	FilePath string
+
+	SkipReason string
+}
+
+type ItemRef struct {
+	ItemId uuid.UUID
+	Name   string
+	Path   string
+}
+
+type Unresolved struct {
+	Field  string
+	Value  string
+	Reason string
 }
 
 type Attr struct {
@@ -51,17 +140,64 @@ type WriteSettings struct {
 	ContentFormat   string
 	ContentLocation string
 	BlobLocation    string
+	MediaRoot       string
 	WriteBlobs      bool
+	Layout          string
+	FileNaming      string
+	BlobFormat      string
+	BlobMetadata    string
+	BlobLayout      string
+	BlobReaders     int
+	BlobWriters     int
+	BlobHashes      map[uuid.UUID]string
+	BlobSkipped     map[uuid.UUID]string
+	BlobMaxSize     int
+	Retry           RetrySettings
+	Renditions      []RenditionSettings
+	MaxPixels       int
 }
 
 type Settings struct {
 	Templates    map[uuid.UUID]TemplateSettings
 	References   map[uuid.UUID]TemplateSettings
+	Datasources  map[uuid.UUID]TemplateSettings
 	BlobSettings BlobSettings
+	Retry        RetrySettings
+	Layout       string
 }
 
 type BlobSettings struct {
 	CustomFields []uuid.UUID
+	Renditions   []RenditionSettings
+	MaxPixels    int
+	Filter       BlobFilter
+}
+
+// extensions and mime types are lower case, extensions without the dot
+type BlobFilter struct {
+	MaxSize         int
+	AllowExtensions []string
+	DenyExtensions  []string
+	AllowMimeTypes  []string
+	DenyMimeTypes   []string
+	IncludePaths    []string
+	ExcludePaths    []string
+}
+
+type RenditionSettings struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+	Format    string
+	Quality   int
+}
+
+type RetrySettings struct {
+	Attempts     int
+	Delay        time.Duration
+	MaxDelay     time.Duration
+	Timeout      time.Duration
+	MaxAbandoned int
 }
 
 type TemplateSettings struct {
@@ -69,18 +205,35 @@ type TemplateSettings struct {
 	Name       string
 	Fields     map[string]FieldSettings
 	Paths      []string
+
+	FollowDatasources bool
 }
 
 type DataPackage struct {
 	ReportItems []data.ItemNode
 	Items       data.ItemMap
 	RefItems    data.ItemMap
+	Datasources data.ItemMap
+	Renderings  data.ItemMap
+
+	paths map[string]data.ItemNode
 }
 
 type FieldSettings struct {
-	Name     string
-	Alias    string
-	RefField string
+	Name       string
+	Alias      string
+	RefField   string
+	Source     string
+	Format     string
+	Transforms []TransformSettings
+}
+
+type TransformSettings struct {
+	Name       string
+	Tags       map[string]bool
+	Attributes map[string]bool
+	Find       *regexp.Regexp
+	Replace    string
 }
 
 type RefTemplate struct {
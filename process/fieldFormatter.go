@@ -8,15 +8,21 @@ var formatters map[string]func(string) string
 
 func init() {
 	formatters = map[string]func(string) string{
-		"Rich Text": formatText,
+		"Rich Text":       formatText,
+		"Multi-Line Text": formatText,
 	}
 }
 
-func formatField(fieldType string, value string) string {
+// fields with transforms configured go through the html pipeline,
+// otherwise the default formatter for the field type is used
+func formatField(fieldType string, value string, transforms []TransformSettings) (string, error) {
+	if len(transforms) > 0 {
+		return transformHtml(value, transforms)
+	}
 	if _, ok := formatters[fieldType]; ok {
-		return formatters[fieldType](value)
+		return formatters[fieldType](value), nil
 	}
-	return value
+	return value, nil
 }
 
 func formatText(val string) string {
@@ -1,9 +1,13 @@
 package process
 
 import (
+	"encoding/xml"
 	"fmt"
 	"log"
+	"path"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/google/uuid"
@@ -14,8 +18,6 @@ import (
 const defaulthandler string = "default"
 
 var mediaReg *regexp.Regexp = regexp.MustCompile(`<(?:image|file) .*?mediaid="\{([A-Fa-f0-9\-]+)\}" ?.*?/>`)
-var mediaRteReg *regexp.Regexp = regexp.MustCompile(`src="-\/media\/([a-f0-9]{32})\.ashx`)
-var linkMediaReg *regexp.Regexp = regexp.MustCompile(`<link .*?linktype="media" .*?id="\{([A-Fa-f0-9\-]+)\}" ?.*?/>`)
 
 type FieldHandler func(
 	fv data.FieldValueNode,
@@ -32,10 +34,17 @@ func init() {
 		"Single-Line Text":      handleString,
 		"text":                  handleString,
 		"Droplink":              handleReference,
+		"Grouped Droplink":      handleReference,
 		"Droptree":              handleReference,
+		"Droplist":              handleDroplist,
+		"Grouped Droplist":      handleDroplist,
 		"Treelist":              handleReferenceList,
+		"TreelistEx":            handleReferenceList,
+		"Tree list":             handleReferenceList,
 		"MultiRoot Treelist":    handleReferenceList,
+		"Multilist":             handleReferenceList,
 		"Multilist with Search": handleReferenceList,
+		"Checklist":             handleReferenceList,
 		"Datetime":              handleString,
 		"Rich Text":             handleRichText,
 		"Multi-Line Text":       handleRichText,
@@ -43,6 +52,7 @@ func init() {
 		"File":                  handleMedia,
 		"attachment":            handleAttachment,
 		"General Link":          handleLink,
+		layoutFieldType:         handleLayout,
 		defaulthandler:          handleString,
 	}
 }
@@ -56,12 +66,21 @@ func ResolveField(
 	bsetting BlobSettings,
 	lang data.Language) (HandlerResult, error) {
 
-	fh, ok := fieldHandlers[tfld.GetType()]
-	if !ok {
-		fh = fieldHandlers[defaulthandler]
-	}
+	return getFieldHandler(tfld.GetType())(fv, item, pkg, fsetting, bsetting, lang)
+}
 
-	return fh(fv, item, pkg, fsetting, bsetting, lang)
+// field types aren't always cased the same, e.g. layout on the standard
+// template and Single-Line Text on most others
+func getFieldHandler(t string) FieldHandler {
+	if fh, ok := fieldHandlers[t]; ok {
+		return fh
+	}
+	for name, fh := range fieldHandlers {
+		if strings.EqualFold(name, t) {
+			return fh
+		}
+	}
+	return fieldHandlers[defaulthandler]
 }
 
 func handleString(
@@ -84,31 +103,30 @@ func handleRichText(
 	lang data.Language) (HandlerResult, error) {
 
 	str := fv.GetValue()
-	allgroups := mediaRteReg.FindAllStringSubmatch(str, -1)
 
 	hr := handlerResult{html: true}
-	for _, m := range allgroups {
-		if len(m) != 2 {
-			continue
-		}
+	str, hr.blobs, hr.unres = replaceRteMedia(str, fv, pkg, bsetting, lang)
+	str, hr.irefs = replaceRteLinks(str, fv, pkg)
 
-		idval := m[1]
-		id, err := uuid.Parse(idval)
-		if err != nil {
-			return handlerResult{}, fmt.Errorf("couldn't parse media id in rte field %s %s. %w", fv.GetName(), fv.GetItemId(), err)
-		}
+	fieldType := ""
+	if tfld := item.GetTemplate().GetField(fv.GetFieldId()); tfld != nil {
+		fieldType = tfld.GetType()
+	}
 
-		b, err := extractBlob(id, pkg, bsetting, lang)
+	formatted, err := formatField(fieldType, str, fsetting.Transforms)
+	if err != nil {
+		return handlerResult{}, fmt.Errorf("handleRichText: formatting. item %v. %w", fv.GetItemId(), err)
+	}
+	hr.value = formatted
+
+	if fsetting.Format == markdownFormat {
+		md, err := htmlToMarkdown(hr.value)
 		if err != nil {
-			return handlerResult{}, fmt.Errorf("handleRichText: extracting blob. item %v. %w", fv.GetItemId(), err)
+			return handlerResult{}, fmt.Errorf("handleRichText: converting to markdown. item %v. %w", fv.GetItemId(), err)
 		}
-		hr.blobs = append(hr.blobs, b)
-
-		str = strings.ReplaceAll(str, "-/media/"+idval+".ashx", "blobref:"+b.GetItemId().String())
+		hr.value = md
 	}
 
-	hr.value = formatText(str)
-
 	return hr, nil
 }
 
@@ -124,32 +142,120 @@ func handleLink(
 	if val == "" {
 		return handlerResult{}, nil
 	}
-	g := linkMediaReg.FindStringSubmatch(val)
 
-	if len(g) != 2 {
-		return handleString(fv, item, pkg, fsetting, bsetting, lang)
-	}
-
-	imageId, err := uuid.Parse(g[1])
+	lx, err := parseLinkXml(val)
 	if err != nil {
-		log.Printf("couldn't parse uuid in general link %v field %s value %s. skipping\n", item.GetId(), fv.GetName(), g[1])
-		return handlerResult{}, err
+		return handlerResult{}, fmt.Errorf("general link not in expected format %s. %w", val, err)
 	}
 
-	blob, err := extractBlob(imageId, pkg, bsetting, lang)
-	if err != nil {
-		log.Printf("couldn't extract blob in general link %v field %s value %v. skipping\n", item.GetId(), fv.GetName(), imageId)
-		return handlerResult{}, err
+	link := &Link{
+		Type:        lx.LinkType,
+		Url:         lx.Url,
+		Text:        lx.Text,
+		Title:       lx.Title,
+		Target:      lx.Target,
+		Anchor:      lx.Anchor,
+		QueryString: lx.QueryString,
+		Class:       lx.Class,
 	}
 
-	newval := strings.Replace(val, g[1], "blobref:"+imageId.String(), 1)
+	hr := handlerResult{}
+	if lx.Id != "" {
+		targetId, err := api.TryParseUUID(lx.Id)
+		if err != nil {
+			log.Printf("couldn't parse uuid in general link %v field %s value %s. skipping\n", item.GetId(), fv.GetName(), lx.Id)
+			return handlerResult{}, err
+		}
+
+		if link.Type == "media" {
+			blobs, err := extractBlob(targetId, pkg, bsetting, lang)
+			if err != nil {
+				log.Printf("couldn't extract blob in general link %v field %s value %v. skipping\n", item.GetId(), fv.GetName(), targetId)
+				return handlerResult{}, err
+			}
+			link.Url = "blobref:" + targetId.String()
+			link.Item = &Item{ID: targetId.String(), Name: blobs[0].GetName(), Path: blobs[0].GetPath()}
+			hr.blobs = append(hr.blobs, blobs...)
+		} else {
+			link.Item = resolveLinkItem(targetId, item, pkg, fsetting, bsetting, lang)
+		}
+	}
 
-	hr := handlerResult{value: newval}
-	hr.blobs = append(hr.blobs, blob)
+	hr.value = link.Url
+	hr.link = link
 
 	return hr, nil
 }
 
+type linkXml struct {
+	LinkType    string `xml:"linktype,attr"`
+	Url         string `xml:"url,attr"`
+	Id          string `xml:"id,attr"`
+	Text        string `xml:"text,attr"`
+	Title       string `xml:"title,attr"`
+	Target      string `xml:"target,attr"`
+	Anchor      string `xml:"anchor,attr"`
+	QueryString string `xml:"querystring,attr"`
+	Class       string `xml:"class,attr"`
+}
+
+func parseLinkXml(val string) (linkXml, error) {
+	lx := linkXml{}
+	err := decodeFieldXml(val, &lx)
+	return lx, err
+}
+
+// link and image field xml, editors and old versions leave unescaped ampersands in attributes
+func decodeFieldXml(val string, v interface{}) error {
+	dec := xml.NewDecoder(strings.NewReader(val))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	return dec.Decode(v)
+}
+
+func findItem(pkg *DataPackage, id uuid.UUID) data.ItemNode {
+	for _, m := range []data.ItemMap{pkg.RefItems, pkg.Items, pkg.Datasources} {
+		if item, ok := m[id]; ok {
+			return item
+		}
+	}
+	return nil
+}
+
+// same lookup order as findItem, refs win when both have the path
+func findItemByPath(pkg *DataPackage, p string) data.ItemNode {
+	if pkg.paths == nil {
+		pkg.paths = make(map[string]data.ItemNode)
+		for _, m := range []data.ItemMap{pkg.Datasources, pkg.Items, pkg.RefItems} {
+			for _, item := range m {
+				pkg.paths[strings.ToLower(item.GetPath())] = item
+			}
+		}
+	}
+	return pkg.paths[strings.ToLower(p)]
+}
+
+// internal links point to any item, look in refs then the exported items.
+// when a ref field is configured, that field comes along with the target
+func resolveLinkItem(id uuid.UUID, item data.ItemNode, pkg *DataPackage, fsetting FieldSettings, bsetting BlobSettings, lang data.Language) *Item {
+	target := findItem(pkg, id)
+	if target == nil {
+		log.Printf("link target not found in item %v value %v. outputting id only\n", item.GetId(), id)
+		return &Item{ID: id.String()}
+	}
+
+	if fsetting.RefField == "" {
+		return &Item{ID: target.GetId().String(), Name: target.GetName(), Path: target.GetPath()}
+	}
+
+	ref, err := resolveReferenceItem(target, pkg, fsetting.RefField, bsetting, lang)
+	if err != nil {
+		log.Printf("couldn't get link target ref field in item %v value %v. %v\n", item.GetId(), id, err)
+	}
+	return &ref
+}
+
 func handleReferenceList(
 	fv data.FieldValueNode,
 	item data.ItemNode,
@@ -208,6 +314,66 @@ func handleReference(
 	return handleReferenceList(fv, item, pkg, fsetting, bsetting, lang)
 }
 
+// droplists store the name of the selected item rather than the id so it is
+// looked up by name, under the source set on the field setting. the template
+// field's own source isn't loaded with the templates
+func handleDroplist(
+	fv data.FieldValueNode,
+	item data.ItemNode,
+	pkg *DataPackage,
+	fsetting FieldSettings,
+	bsetting BlobSettings,
+	lang data.Language) (HandlerResult, error) {
+
+	val := fv.GetValue()
+	if val == "" {
+		return handlerResult{}, nil
+	}
+
+	refitem := findDroplistItem(pkg, val, fsetting.Source)
+	if refitem == nil {
+		reason := "no item found with that name"
+		if fsetting.Source != "" {
+			reason += " under " + fsetting.Source
+		}
+		log.Printf("droplist item not found by name in item %v field %v value %s source %s. using name only\n", item.GetId(), fv.GetName(), val, fsetting.Source)
+		return handlerResult{value: val, unres: []Unresolved{{Field: fv.GetName(), Value: val, Reason: reason}}}, nil
+	}
+
+	ref, err := resolveReferenceItem(refitem, pkg, fsetting.RefField, bsetting, lang)
+	if err != nil {
+		log.Printf("couldn't get referenced droplist item. item %v field %v value %s. using name only\n", item.GetId(), fv.GetName(), val)
+		return handlerResult{value: val, unres: []Unresolved{{Field: fv.GetName(), Value: val, Reason: err.Error()}}}, nil
+	}
+
+	return handlerResult{value: val, refs: []Item{ref}}, nil
+}
+
+// same lookup order as findItem
+func findDroplistItem(pkg *DataPackage, name, source string) data.ItemNode {
+	for _, m := range []data.ItemMap{pkg.RefItems, pkg.Items, pkg.Datasources} {
+		if item := findItemByName(m, name, source); item != nil {
+			return item
+		}
+	}
+	return nil
+}
+
+func findItemByName(m data.ItemMap, name, source string) data.ItemNode {
+	source = strings.ToLower(strings.TrimSuffix(source, "/"))
+	var found data.ItemNode
+	for _, item := range m.FindItems(name) {
+		if source != "" && !strings.HasPrefix(strings.ToLower(item.GetPath()), source+"/") {
+			continue
+		}
+		// map order is random, keep the result stable when names repeat
+		if found == nil || item.GetPath() < found.GetPath() {
+			found = item
+		}
+	}
+	return found
+}
+
 func handleMedia(
 	fv data.FieldValueNode,
 	item data.ItemNode,
@@ -228,32 +394,168 @@ func handleMedia(
 	if err != nil {
 		return handlerResult{}, fmt.Errorf("image field not in expected format %s parsed %s %w", fv.GetValue(), g[1], err)
 	}
-	b, err := extractBlob(id, pkg, bsetting, lang)
+	blobs, err := extractBlob(id, pkg, bsetting, lang)
 	if err != nil {
 		return handlerResult{}, fmt.Errorf("handleImage: extracting blob. %w", err)
 	}
 
-	hr := handlerResult{id: id.String(), path: b.GetPath(), value: "blobref:" + id.String()}
-	hr.blobs = append(hr.blobs, b)
+	hr := handlerResult{id: id.String(), path: blobs[0].GetPath(), value: "blobref:" + id.String()}
+	hr.blobs = append(hr.blobs, blobs...)
+
+	if strings.HasPrefix(strings.TrimSpace(val), "<image") {
+		img, err := getImage(val, id, pkg, lang)
+		if err != nil {
+			log.Printf("couldn't read image attributes in item %v field %s value %s. %v\n", item.GetId(), fv.GetName(), val, err)
+		} else {
+			hr.image = img
+		}
+	}
 
 	return hr, nil
 }
 
+type imageXml struct {
+	Alt    string `xml:"alt,attr"`
+	Width  string `xml:"width,attr"`
+	Height string `xml:"height,attr"`
+	HSpace string `xml:"hspace,attr"`
+	VSpace string `xml:"vspace,attr"`
+	Class  string `xml:"class,attr"`
+}
+
+// the alt text entered on the image field wins, the media item's alt is used when it's blank
+func getImage(val string, mediaId uuid.UUID, pkg *DataPackage, lang data.Language) (*Image, error) {
+	ix := imageXml{}
+	if err := decodeFieldXml(val, &ix); err != nil {
+		return nil, err
+	}
+
+	img := &Image{Alt: ix.Alt, Width: ix.Width, Height: ix.Height, HSpace: ix.HSpace, VSpace: ix.VSpace, Class: ix.Class}
+	if img.Alt != "" {
+		img.AltSource = fieldAltSource
+	} else if media, ok := pkg.RefItems[mediaId]; ok {
+		img.Alt = getMediaFieldValue(media, "Alt", lang)
+		if img.Alt != "" {
+			img.AltSource = mediaAltSource
+		}
+	}
+	return img, nil
+}
+
+func getMediaFieldValue(media data.ItemNode, name string, lang data.Language) string {
+	fld := media.GetTemplate().FindField(name)
+	if fld == nil {
+		return ""
+	}
+	fv := media.GetFieldValue(fld.GetId(), lang)
+	if fv == nil {
+		return ""
+	}
+	return fv.GetValue()
+}
+
 // image id points to the media library
-// will find out the blob data and return that
-func extractBlob(mediaId uuid.UUID, pkg *DataPackage, bsetting BlobSettings, lang data.Language) (BlobResult, error) {
+// will find out the blob data and return that. versioned media has a blob
+// per language, the active language's is first
+func extractBlob(mediaId uuid.UUID, pkg *DataPackage, bsetting BlobSettings, lang data.Language) ([]BlobResult, error) {
 	media, ok := pkg.RefItems[mediaId]
 	if !ok {
-		return blobResult{}, fmt.Errorf("referenced blob not found %v", mediaId)
+		return nil, fmt.Errorf("referenced blob not found %v", mediaId)
 	}
+	return getMediaBlobs(media, bsetting, lang)
+}
 
-	blobfld := media.GetTemplate().FindField("Blob")
-	extfld := media.GetTemplate().FindField("Extension")
-
+func getMediaBlobs(media data.ItemNode, bsetting BlobSettings, lang data.Language) ([]BlobResult, error) {
+	blobfld, extfld, versioned := getMediaBlobFields(media.GetTemplate())
 	if blobfld == nil || extfld == nil {
-		return blobResult{}, fmt.Errorf("blob fields not found. ID: %v Blob: %v  Extension: %v", mediaId, blobfld == nil, extfld == nil)
+		return nil, fmt.Errorf("blob fields not found. ID: %v Blob: %v  Extension: %v", media.GetId(), blobfld == nil, extfld == nil)
+	}
+
+	if !versioned {
+		b, err := getMediaBlob(media, blobfld, extfld, bsetting, lang)
+		if err != nil {
+			return nil, err
+		}
+		return []BlobResult{b}, nil
+	}
+
+	fileIds := []uuid.UUID{blobfld.GetId()}
+	if fpfld := media.GetTemplate().FindField(filePathFieldName); fpfld != nil {
+		fileIds = append(fileIds, fpfld.GetId())
+	}
+
+	langs := getBlobLanguages(media, fileIds, lang)
+	if len(langs) == 0 {
+		return nil, fmt.Errorf("no language has a blob on versioned media item %v, field id is %v", media.GetId(), blobfld.GetId())
+	}
+
+	// languages can share a blob, it's only exported once with the first
+	// language's filename
+	seen := make(map[uuid.UUID]bool)
+	blobs := []BlobResult{}
+	for i, l := range langs {
+		b, err := getMediaBlob(media, blobfld, extfld, bsetting, l)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			log.Printf("couldn't get %s blob on versioned media item %v. %v\n", l, media.GetId(), err)
+			continue
+		}
+		if seen[b.blobId] {
+			continue
+		}
+		seen[b.blobId] = true
+
+		// every language would have the same filename otherwise
+		b.lang = string(l)
+		if i > 0 {
+			b.name += "." + strings.ToLower(string(l))
+		}
+		blobs = append(blobs, b)
 	}
+	return blobs, nil
+}
+
+// versioned media templates have their own blob fields, which have a value
+// per language and version
+func getMediaBlobFields(t data.TemplateNode) (data.TemplateFieldNode, data.TemplateFieldNode, bool) {
+	if blobfld := t.GetField(data.VersionedBlobFieldId); blobfld != nil {
+		return blobfld, t.GetField(data.VersionedExtensionFieldId), true
+	}
+	return t.FindField("Blob"), t.FindField("Extension"), false
+}
 
+// languages whose latest version has a blob or a file path, sorted with the
+// active language first
+func getBlobLanguages(media data.ItemNode, fileIds []uuid.UUID, lang data.Language) []data.Language {
+	seen := make(map[data.Language]bool)
+	langs := []data.Language{}
+	for _, fv := range media.GetFieldValues() {
+		l := fv.GetLanguage()
+		if l == data.None || seen[l] || !containsId(fileIds, fv.GetFieldId()) {
+			continue
+		}
+
+		for _, id := range fileIds {
+			if latest := media.GetFieldValue(id, l); latest != nil && latest.GetValue() != "" {
+				seen[l] = true
+				langs = append(langs, l)
+				break
+			}
+		}
+	}
+
+	sort.Slice(langs, func(i, j int) bool {
+		if langs[i] == lang || langs[j] == lang {
+			return langs[i] == lang
+		}
+		return langs[i] < langs[j]
+	})
+	return langs
+}
+
+func getMediaBlob(media data.ItemNode, blobfld, extfld data.TemplateFieldNode, bsetting BlobSettings, lang data.Language) (blobResult, error) {
 	blobidfv := media.GetFieldValue(blobfld.GetId(), lang)
 	extfv := media.GetFieldValue(extfld.GetId(), lang)
 
@@ -268,21 +570,53 @@ func extractBlob(mediaId uuid.UUID, pkg *DataPackage, bsetting BlobSettings, lan
 		}
 	}
 
-	// no actual blob
-	if blobidfv == nil {
-		return blobResult{}, fmt.Errorf("no blob id field value exists on media item %v, field id is %v", mediaId, blobfld.GetId())
+	ext := ""
+	if extfv != nil {
+		ext = extfv.GetValue()
+	}
+
+	b := blobResult{itemId: media.GetId(), name: media.GetName(), ext: ext, path: media.GetPath(), attrs: attrs, mime: getMediaFieldValue(media, "Mime Type", lang)}
+	b.size, _ = strconv.Atoi(getMediaFieldValue(media, "Size", lang))
+
+	// no actual blob, older sites keep some media on disk instead
+	if blobidfv == nil || blobidfv.GetValue() == "" {
+		fp := getMediaFieldValue(media, filePathFieldName, lang)
+		if fp == "" {
+			return blobResult{}, fmt.Errorf("no blob id field value exists on media item %v, field id is %v", media.GetId(), blobfld.GetId())
+		}
+
+		b.blobId, b.filePath = getFilePathBlobId(fp), fp
+		if b.ext == "" {
+			b.ext = strings.TrimPrefix(path.Ext(fp), ".")
+		}
+		return b, nil
 	}
 
 	blobId, err := uuid.Parse(blobidfv.GetValue())
 	if err != nil {
 		return blobResult{}, fmt.Errorf("blob field is invalid format %s %w", blobidfv.GetValue(), err)
 	}
-
-	b := blobResult{blobId: blobId, itemId: mediaId, name: media.GetName(), ext: extfv.GetValue(), path: media.GetPath(), attrs: attrs}
+	b.blobId = blobId
 
 	return b, nil
 }
 
+// file path media has no blob id, it gets one from the path so the same
+// file is only written once and keeps its id between runs
+func getFilePathBlobId(fp string) uuid.UUID {
+	fp = strings.ToLower(strings.ReplaceAll(fp, "\\", "/"))
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte("file://"+strings.TrimPrefix(fp, "~")))
+}
+
+func containsId(list []uuid.UUID, id uuid.UUID) bool {
+	for _, v := range list {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
 func handleAttachment(
 	fv data.FieldValueNode,
 	item data.ItemNode,
@@ -291,26 +625,40 @@ func handleAttachment(
 	bsetting BlobSettings,
 	lang data.Language) (HandlerResult, error) {
 
+	blobfld, extfld, versioned := getMediaBlobFields(item.GetTemplate())
+	if blobfld == nil || extfld == nil {
+		return nil, fmt.Errorf("blob fields not found. ID: %v Blob: %v  Extension: %v ", item.GetId(), blobfld == nil, extfld == nil)
+	}
+
+	// the item's own blob goes through the media item, so one without a blob
+	// id gets its file path. a versioned one comes with the other languages'
 	val := fv.GetValue()
-	// attachment field just has a blob id
-	blobId, err := api.TryParseUUID(val)
+	if fv.GetFieldId() == blobfld.GetId() {
+		blobs, err := getMediaBlobs(item, bsetting, lang)
+		if err != nil {
+			return nil, fmt.Errorf("getting blob from attachment. item id: %v. %w", item.GetId(), err)
+		}
+		id := blobs[0].GetBlobId()
+		if vid, err := api.TryParseUUID(val); versioned && val != "" && err == nil {
+			id = vid
+		}
+		return handlerResult{value: "blobref:" + id.String(), blobs: blobs}, nil
+	}
 
+	// any other attachment field just has a blob id
+	blobId, err := api.TryParseUUID(val)
 	if val == "" || err != nil {
 		return nil, fmt.Errorf("parsing blob id from attachment. item id: %v field id: %v field value %s. %w", item.GetId(), fv.GetFieldId(), val, err)
 	}
 
-	blobfld := item.GetTemplate().FindField("Blob")
-	extfld := item.GetTemplate().FindField("Extension")
-	if blobfld == nil || extfld == nil {
-		return nil, fmt.Errorf("blob fields not found. ID: %v Blob: %v  Extension: %v ", item.GetId(), blobfld == nil, extfld == nil)
-	}
 	extfv := item.GetFieldValue(extfld.GetId(), lang)
 	ext := ""
 	if extfv != nil {
 		ext = extfv.GetValue()
 	}
 
-	b := blobResult{itemId: item.GetId(), blobId: blobId, name: item.GetName(), ext: ext, path: item.GetPath()}
+	b := blobResult{itemId: item.GetId(), blobId: blobId, name: item.GetName(), ext: ext, path: item.GetPath(), mime: getMediaFieldValue(item, "Mime Type", lang)}
+	b.size, _ = strconv.Atoi(getMediaFieldValue(item, "Size", lang))
 
 	hr := handlerResult{value: "blobref:" + b.blobId.String()}
 	hr.blobs = append(hr.blobs, b)
@@ -0,0 +1,42 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+func TestHandleDroplistUnresolved(t *testing.T) {
+	item := data.NewItemNode(uuid.New(), "Shirt", uuid.New(), uuid.Nil, uuid.Nil, time.Now(), time.Now())
+	fv := data.NewFieldValue(uuid.New(), item.GetId(), "Color", "Red", testLanguage, 1, time.Now(), time.Now(), data.SharedFields)
+
+	res, err := handleDroplist(fv, item, &DataPackage{}, FieldSettings{Source: "/sitecore/content/lists/colors"}, BlobSettings{}, testLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetValue() != "Red" {
+		t.Errorf("expected the name as the value, got %s", res.GetValue())
+	}
+	u := res.GetUnresolved()
+	if len(u) != 1 || u[0].Field != "Color" || u[0].Value != "Red" || u[0].Reason != "no item found with that name under /sitecore/content/lists/colors" {
+		t.Errorf("expected the name to be unresolved, got %+v", u)
+	}
+}
+
+func TestFindDroplistItem(t *testing.T) {
+	red := data.NewItemNode(uuid.New(), "Red", uuid.New(), uuid.Nil, uuid.Nil, time.Now(), time.Now())
+	red.SetPath("/sitecore/content/lists/colors/Red")
+	other := data.NewItemNode(uuid.New(), "Red", uuid.New(), uuid.Nil, uuid.Nil, time.Now(), time.Now())
+	other.SetPath("/sitecore/content/lists/teams/Red")
+
+	// not a reference template, still found
+	pkg := &DataPackage{Items: data.ItemMap{red.GetId(): red, other.GetId(): other}}
+	if found := findDroplistItem(pkg, "Red", "/sitecore/content/lists/colors/"); found == nil || found.GetId() != red.GetId() {
+		t.Errorf("expected the item under the source, got %v", found)
+	}
+	if found := findDroplistItem(pkg, "Blue", ""); found != nil {
+		t.Errorf("expected nothing, got %v", found.GetPath())
+	}
+}
@@ -9,6 +9,10 @@ type BlobResult interface {
 	GetAttrs() []Attr
 	GetExt() string
 	GetPath() string
+	GetMimeType() string
+	GetSize() int
+	GetLanguage() string
+	GetFilePath() string
 }
 
 type HandlerResult interface {
@@ -19,6 +23,11 @@ type HandlerResult interface {
 	GetBlobs() []BlobResult
 	HasMultiple() bool
 	GetReferences() []Item
+	GetLink() *Link
+	GetItemRefs() []ItemRef
+	GetUnresolved() []Unresolved
+	GetImage() *Image
+	GetLayout() *Layout
 }
 
 type blobResult struct {
@@ -28,15 +37,25 @@ type blobResult struct {
 	attrs  []Attr
 	ext    string
 	path   string
+	mime   string
+	size   int
+	lang   string
+	// media stored on disk
+	filePath string
 }
 
 type handlerResult struct {
-	id    string
-	path  string
-	value string
-	blobs []BlobResult
-	html  bool
-	refs  []Item
+	id     string
+	path   string
+	value  string
+	blobs  []BlobResult
+	html   bool
+	refs   []Item
+	link   *Link
+	irefs  []ItemRef
+	unres  []Unresolved
+	image  *Image
+	layout *Layout
 }
 
 func (h handlerResult) GetId() string {
@@ -67,6 +86,26 @@ func (h handlerResult) GetReferences() []Item {
 	return h.refs
 }
 
+func (h handlerResult) GetLink() *Link {
+	return h.link
+}
+
+func (h handlerResult) GetItemRefs() []ItemRef {
+	return h.irefs
+}
+
+func (h handlerResult) GetUnresolved() []Unresolved {
+	return h.unres
+}
+
+func (h handlerResult) GetImage() *Image {
+	return h.image
+}
+
+func (h handlerResult) GetLayout() *Layout {
+	return h.layout
+}
+
 func (b blobResult) GetBlobId() uuid.UUID {
 	return b.blobId
 }
@@ -88,3 +127,18 @@ func (b blobResult) GetExt() string {
 func (b blobResult) GetPath() string {
 	return b.path
 }
+func (b blobResult) GetMimeType() string {
+	return b.mime
+}
+
+func (b blobResult) GetSize() int {
+	return b.size
+}
+
+func (b blobResult) GetLanguage() string {
+	return b.lang
+}
+
+func (b blobResult) GetFilePath() string {
+	return b.filePath
+}
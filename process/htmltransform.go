@@ -0,0 +1,251 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const replaceTransform string = "replace"
+
+type htmlTransform func(root *html.Node, ts TransformSettings)
+
+var htmlTransforms map[string]htmlTransform
+
+var transformSpaceReg *regexp.Regexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+
+func init() {
+	htmlTransforms = map[string]htmlTransform{
+		"stripStyles":             stripStyles,
+		"stripSitecoreAttributes": stripSitecoreAttributes,
+		"allowTags":               allowTags,
+		"allowAttributes":         allowAttributes,
+		"unwrap":                  unwrapTags,
+		"removeEmpty":             removeEmpty,
+		"normalizeWhitespace":     normalizeWhitespace,
+	}
+}
+
+func isTransform(name string) bool {
+	_, ok := htmlTransforms[name]
+	return ok || name == replaceTransform
+}
+
+// runs each transform in order on the parsed html. the replace step works
+// on the html text so it's rendered and parsed again around it
+func transformHtml(val string, transforms []TransformSettings) (string, error) {
+	root, err := parseHtmlFragment(val)
+	if err != nil {
+		return val, err
+	}
+
+	for _, ts := range transforms {
+		if ts.Name == replaceTransform {
+			s, err := renderHtmlFragment(root)
+			if err != nil {
+				return val, err
+			}
+			s = ts.Find.ReplaceAllString(s, ts.Replace)
+			root, err = parseHtmlFragment(s)
+			if err != nil {
+				return val, err
+			}
+			continue
+		}
+
+		t, ok := htmlTransforms[ts.Name]
+		if !ok {
+			return val, fmt.Errorf("unknown html transform %s", ts.Name)
+		}
+		t(root, ts)
+	}
+
+	return renderHtmlFragment(root)
+}
+
+func parseHtmlFragment(val string) (*html.Node, error) {
+	ctx := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(val), ctx)
+	if err != nil {
+		return nil, fmt.Errorf("parsing html. %w", err)
+	}
+
+	root := &html.Node{Type: html.DocumentNode}
+	for _, n := range nodes {
+		root.AppendChild(n)
+	}
+	return root, nil
+}
+
+func renderHtmlFragment(root *html.Node) (string, error) {
+	var buf bytes.Buffer
+	for c := root.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", fmt.Errorf("rendering html. %w", err)
+		}
+	}
+	return buf.String(), nil
+}
+
+// elements are collected first so transforms can change the tree while going through them
+func htmlElements(root *html.Node) []*html.Node {
+	list := []*html.Node{}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				list = append(list, c)
+			}
+			walk(c)
+		}
+	}
+	walk(root)
+	return list
+}
+
+func filterAttrs(n *html.Node, keep func(a html.Attribute) bool) {
+	attrs := []html.Attribute{}
+	for _, a := range n.Attr {
+		if keep(a) {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+func unwrapNode(n *html.Node) {
+	p := n.Parent
+	if p == nil {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = n.FirstChild {
+		n.RemoveChild(c)
+		p.InsertBefore(c, n)
+	}
+	p.RemoveChild(n)
+}
+
+func stripStyles(root *html.Node, ts TransformSettings) {
+	for _, n := range htmlElements(root) {
+		filterAttrs(n, func(a html.Attribute) bool {
+			return !strings.EqualFold(a.Key, "style")
+		})
+	}
+}
+
+// attributes the page editor and renderings leave behind, like sc_item, sc-part-of and data-sc-*
+func stripSitecoreAttributes(root *html.Node, ts TransformSettings) {
+	for _, n := range htmlElements(root) {
+		filterAttrs(n, func(a html.Attribute) bool {
+			k := strings.ToLower(a.Key)
+			return !(strings.HasPrefix(k, "sc_") || strings.HasPrefix(k, "sc-") || strings.HasPrefix(k, "data-sc") || k == "scfieldtype")
+		})
+	}
+}
+
+// tags that aren't allowed are unwrapped so their text stays, except the ones whose text isn't content
+func allowTags(root *html.Node, ts TransformSettings) {
+	for _, n := range htmlElements(root) {
+		if ts.Tags[n.Data] {
+			continue
+		}
+		switch n.DataAtom {
+		case atom.Script, atom.Style, atom.Noscript, atom.Template:
+			if n.Parent != nil {
+				n.Parent.RemoveChild(n)
+			}
+		default:
+			unwrapNode(n)
+		}
+	}
+}
+
+func allowAttributes(root *html.Node, ts TransformSettings) {
+	for _, n := range htmlElements(root) {
+		filterAttrs(n, func(a html.Attribute) bool {
+			k := strings.ToLower(a.Key)
+			return ts.Attributes[k] || ts.Attributes[n.Data+"."+k]
+		})
+	}
+}
+
+// unwraps spans unless other tags are given
+func unwrapTags(root *html.Node, ts TransformSettings) {
+	for _, n := range htmlElements(root) {
+		if (len(ts.Tags) == 0 && n.DataAtom == atom.Span) || ts.Tags[n.Data] {
+			unwrapNode(n)
+		}
+	}
+}
+
+// removes paragraphs, or the given tags, that have nothing but whitespace and &nbsp; in them
+func removeEmpty(root *html.Node, ts TransformSettings) {
+	list := htmlElements(root)
+	for i := len(list) - 1; i >= 0; i-- {
+		n := list[i]
+		if (len(ts.Tags) == 0 && n.DataAtom != atom.P) || (len(ts.Tags) > 0 && !ts.Tags[n.Data]) {
+			continue
+		}
+		if isEmptyNode(n) && n.Parent != nil {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+func isEmptyNode(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		switch c.Type {
+		case html.TextNode:
+			if strings.TrimSpace(strings.ReplaceAll(c.Data, "\u00a0", " ")) != "" {
+				return false
+			}
+		case html.ElementNode:
+			if c.DataAtom != atom.Br {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// collapses whitespace in text outside of pre, and drops whitespace between blocks
+func normalizeWhitespace(root *html.Node, ts TransformSettings) {
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			switch c.Type {
+			case html.TextNode:
+				c.Data = transformSpaceReg.ReplaceAllString(c.Data, " ")
+				if c.Data == " " && isBlockBoundary(c) {
+					n.RemoveChild(c)
+				}
+			case html.ElementNode:
+				if c.DataAtom != atom.Pre && c.DataAtom != atom.Textarea {
+					walk(c)
+				}
+			}
+			c = next
+		}
+	}
+	walk(root)
+}
+
+// next to a block, or first or last in one. first or last in an inline
+// element like a span isn't, the space is still between two words
+func isBlockBoundary(n *html.Node) bool {
+	isBlock := func(s *html.Node) bool {
+		return s.Type == html.DocumentNode || (s.Type == html.ElementNode && (mdBlockElements[s.DataAtom] || mdContainers[s.DataAtom]))
+	}
+	isEdge := func(s *html.Node) bool {
+		if s == nil {
+			return n.Parent == nil || isBlock(n.Parent)
+		}
+		return isBlock(s)
+	}
+	return isEdge(n.PrevSibling) || isEdge(n.NextSibling)
+}
@@ -0,0 +1,21 @@
+package process
+
+import "testing"
+
+func TestNormalizeWhitespace(t *testing.T) {
+	ts := []TransformSettings{{Name: "normalizeWhitespace"}}
+	for in, expected := range map[string]string{
+		"one<span> </span>two":          "one<span> </span>two",
+		"<p>one</p>\n  <p>two</p>":      "<p>one</p><p>two</p>",
+		"<p> one  <b>two</b>\n</p>":     "<p> one <b>two</b></p>",
+		"<div>\n<span>a</span>\n</div>": "<div><span>a</span></div>",
+	} {
+		out, err := transformHtml(in, ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != expected {
+			t.Errorf("%q expected %q, got %q", in, expected, out)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package process
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/api"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+const (
+	layoutFieldType  string = "layout"
+	layoutSetSpace   string = "s"
+	layoutPatchSpace string = "p"
+)
+
+var layoutUidReg *regexp.Regexp = regexp.MustCompile(`@uid='(\{?[A-Fa-f0-9\-]+\}?)'`)
+
+var renderingTemplateIds []uuid.UUID = []uuid.UUID{
+	data.ControllerRenderingId,
+	data.ItemRenderingId,
+	data.MethodRenderingId,
+	data.SublayoutRenderingId,
+	data.UrlRenderingId,
+	data.ViewRenderingId,
+	data.WebControlRenderingId,
+	data.XmlControlRenderingId,
+	data.XslRenderingId,
+	data.LayoutTemplateId,
+}
+
+type layoutXml struct {
+	XMLName xml.Name          `xml:"r"`
+	Attrs   []xml.Attr        `xml:",any,attr"`
+	Devices []layoutDeviceXml `xml:"d"`
+}
+
+type layoutDeviceXml struct {
+	Attrs      []xml.Attr           `xml:",any,attr"`
+	Renderings []layoutRenderingXml `xml:"r"`
+}
+
+type layoutRenderingXml struct {
+	Attrs  []xml.Attr `xml:",any,attr"`
+	Delete *struct{}  `xml:"d"`
+}
+
+// the final layout is the shared layout with the final renderings delta applied to it,
+// and the shared layout is a delta on the standard values' shared layout. so the
+// chain is standard values __Renderings, item __Renderings, standard values
+// __Final Renderings then item __Final Renderings, each one applied if it's there
+func handleLayout(
+	fv data.FieldValueNode,
+	item data.ItemNode,
+	pkg *DataPackage,
+	fsetting FieldSettings,
+	bsetting BlobSettings,
+	lang data.Language) (HandlerResult, error) {
+
+	layout, err := getItemLayout(item, fv.GetFieldId() == data.FinalRenderingsFieldId, pkg, lang)
+	if err != nil {
+		return handlerResult{}, err
+	}
+
+	return handlerResult{layout: layout}, nil
+}
+
+// the standard fields have it lower case, templates made by hand may not
+func isLayoutField(fld data.TemplateFieldNode) bool {
+	return strings.EqualFold(fld.GetType(), layoutFieldType)
+}
+
+func getItemLayout(item data.ItemNode, final bool, pkg *DataPackage, lang data.Language) (*Layout, error) {
+	fieldIds := []uuid.UUID{data.RenderingsFieldId}
+	if final {
+		fieldIds = append(fieldIds, data.FinalRenderingsFieldId)
+	}
+
+	var sv data.ItemNode
+	if t := item.GetTemplate(); t != nil {
+		sv = t.GetStandardValues()
+	}
+
+	var merged *layoutXml
+	for _, fid := range fieldIds {
+		for _, src := range []data.ItemNode{sv, item} {
+			if src == nil {
+				continue
+			}
+			lfv := src.GetFieldValue(fid, lang)
+			if lfv == nil || strings.TrimSpace(lfv.GetValue()) == "" {
+				continue
+			}
+
+			lx, err := parseLayoutXml(lfv.GetValue())
+			if err != nil {
+				return nil, fmt.Errorf("parsing layout field %v on item %v. %w", fid, src.GetId(), err)
+			}
+			merged = applyLayoutDelta(merged, lx)
+		}
+	}
+
+	if merged == nil {
+		return nil, nil
+	}
+
+	return getLayout(merged, item, pkg), nil
+}
+
+func parseLayoutXml(val string) (*layoutXml, error) {
+	lx := &layoutXml{}
+	dec := xml.NewDecoder(strings.NewReader(val))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	err := dec.Decode(lx)
+	return lx, err
+}
+
+func isLayoutDelta(lx *layoutXml) bool {
+	_, ok := getLayoutAttr(lx.Attrs, layoutPatchSpace, "p")
+	return ok
+}
+
+func getLayoutAttr(attrs []xml.Attr, space, name string) (string, bool) {
+	for _, a := range attrs {
+		if a.Name.Space == space && strings.EqualFold(a.Name.Local, name) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// plain and s: attributes both set values, p: attributes are patch instructions
+func getLayoutValue(attrs []xml.Attr, name string) string {
+	if v, ok := getLayoutAttr(attrs, "", name); ok {
+		return v
+	}
+	v, _ := getLayoutAttr(attrs, layoutSetSpace, name)
+	return v
+}
+
+func setLayoutAttrs(attrs []xml.Attr, delta []xml.Attr) []xml.Attr {
+	for _, d := range delta {
+		if d.Name.Space != "" && d.Name.Space != layoutSetSpace {
+			continue
+		}
+		found := false
+		for i, a := range attrs {
+			if a.Name.Space == "" && a.Name.Local == d.Name.Local {
+				attrs[i].Value = d.Value
+				found = true
+			}
+		}
+		if !found {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: d.Name.Local}, Value: d.Value})
+		}
+	}
+	return attrs
+}
+
+func applyLayoutDelta(base *layoutXml, delta *layoutXml) *layoutXml {
+	if base == nil || !isLayoutDelta(delta) {
+		merged := &layoutXml{}
+		for _, d := range delta.Devices {
+			md := layoutDeviceXml{Attrs: setLayoutAttrs(nil, d.Attrs)}
+			for _, r := range d.Renderings {
+				if r.Delete != nil {
+					continue
+				}
+				md.Renderings = append(md.Renderings, layoutRenderingXml{Attrs: setLayoutAttrs(nil, r.Attrs)})
+			}
+			merged.Devices = append(merged.Devices, md)
+		}
+		return merged
+	}
+
+	for _, d := range delta.Devices {
+		did := getLayoutValue(d.Attrs, "id")
+		idx := -1
+		for i, bd := range base.Devices {
+			if strings.EqualFold(getLayoutValue(bd.Attrs, "id"), did) {
+				idx = i
+			}
+		}
+		if idx == -1 {
+			base.Devices = append(base.Devices, layoutDeviceXml{})
+			idx = len(base.Devices) - 1
+		}
+
+		bd := &base.Devices[idx]
+		bd.Attrs = setLayoutAttrs(bd.Attrs, d.Attrs)
+
+		for _, r := range d.Renderings {
+			uid := getLayoutValue(r.Attrs, "uid")
+			ridx := -1
+			for i, br := range bd.Renderings {
+				if uid != "" && strings.EqualFold(getLayoutValue(br.Attrs, "uid"), uid) {
+					ridx = i
+				}
+			}
+
+			if r.Delete != nil {
+				if ridx != -1 {
+					bd.Renderings = append(bd.Renderings[:ridx], bd.Renderings[ridx+1:]...)
+				}
+				continue
+			}
+
+			var rend layoutRenderingXml
+			if ridx != -1 {
+				rend = bd.Renderings[ridx]
+				bd.Renderings = append(bd.Renderings[:ridx], bd.Renderings[ridx+1:]...)
+			}
+			rend.Attrs = setLayoutAttrs(rend.Attrs, r.Attrs)
+			bd.Renderings = insertRendering(bd.Renderings, rend, r.Attrs, ridx)
+		}
+	}
+
+	return base
+}
+
+// p:before and p:after are xpath like r[@uid='{...}'], renderings without them stay where they were
+func insertRendering(list []layoutRenderingXml, rend layoutRenderingXml, delta []xml.Attr, orig int) []layoutRenderingXml {
+	pos := orig
+	if pos == -1 || pos > len(list) {
+		pos = len(list)
+	}
+
+	for _, name := range []string{"before", "after"} {
+		v, ok := getLayoutAttr(delta, layoutPatchSpace, name)
+		if !ok {
+			continue
+		}
+		pos = len(list)
+		if g := layoutUidReg.FindStringSubmatch(v); len(g) == 2 {
+			for i, r := range list {
+				if strings.EqualFold(getLayoutValue(r.Attrs, "uid"), g[1]) {
+					pos = i
+					if name == "after" {
+						pos = i + 1
+					}
+				}
+			}
+		}
+	}
+
+	list = append(list, layoutRenderingXml{})
+	copy(list[pos+1:], list[pos:])
+	list[pos] = rend
+	return list
+}
+
+func getLayout(lx *layoutXml, item data.ItemNode, pkg *DataPackage) *Layout {
+	layout := &Layout{}
+	for _, d := range lx.Devices {
+		dev := LayoutDevice{ID: getLayoutId(getLayoutValue(d.Attrs, "id")), LayoutId: getLayoutId(getLayoutValue(d.Attrs, "l"))}
+		dev.Name = getLayoutItemName(pkg, dev.ID)
+		dev.LayoutName = getLayoutItemName(pkg, dev.LayoutId)
+
+		for _, r := range d.Renderings {
+			rend := LayoutRendering{
+				ID:          getLayoutId(getLayoutValue(r.Attrs, "id")),
+				Uid:         getLayoutId(getLayoutValue(r.Attrs, "uid")),
+				Placeholder: getLayoutValue(r.Attrs, "ph"),
+			}
+			rend.Name = getLayoutItemName(pkg, rend.ID)
+
+			if ds := getLayoutValue(r.Attrs, "ds"); ds != "" {
+				rend.DataSource = getDataSource(ds, item, pkg)
+			}
+			rend.Parameters = getRenderingParameters(getLayoutValue(r.Attrs, "par"))
+
+			dev.Renderings = append(dev.Renderings, rend)
+		}
+		layout.Devices = append(layout.Devices, dev)
+	}
+	return layout
+}
+
+func getLayoutId(val string) string {
+	if val == "" {
+		return ""
+	}
+	id, err := api.TryParseUUID(val)
+	if err != nil {
+		return val
+	}
+	return id.String()
+}
+
+func getLayoutItemName(pkg *DataPackage, id string) string {
+	uid, err := uuid.Parse(id)
+	if err != nil {
+		return ""
+	}
+	if r, ok := pkg.Renderings[uid]; ok {
+		return r.GetName()
+	}
+	return ""
+}
+
+// datasources are an id, a path, or local:/path relative to the page
+func getDataSource(ds string, item data.ItemNode, pkg *DataPackage) *DataSource {
+	result := &DataSource{Value: ds}
+
+	var target data.ItemNode
+	if id, err := api.TryParseUUID(ds); err == nil {
+		result.ID = id.String()
+		target = findItem(pkg, id)
+	} else {
+		p := ds
+		if strings.HasPrefix(p, "local:") {
+			p = item.GetPath() + strings.TrimPrefix(p, "local:")
+		}
+		result.Path = p
+		target = findItemByPath(pkg, p)
+	}
+
+	if target == nil {
+		log.Printf("datasource not found on item %v value %s\n", item.GetId(), ds)
+		return result
+	}
+
+	result.ID = target.GetId().String()
+	result.Path = target.GetPath()
+	return result
+}
+
+func getRenderingParameters(par string) []Attr {
+	var params []Attr
+	if par == "" {
+		return params
+	}
+	for _, p := range strings.Split(par, "&") {
+		if p == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(p, "=")
+		if uk, err := url.QueryUnescape(k); err == nil {
+			k = uk
+		}
+		if uv, err := url.QueryUnescape(v); err == nil {
+			v = uv
+		}
+		params = append(params, Attr{Name: k, Value: v})
+	}
+	return params
+}
@@ -0,0 +1,86 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+const testLanguage data.Language = "en"
+
+// a page template with the standard layout fields, typed the way sitecore's
+// standard template has them
+func newLayoutTestItem(shared, final string) data.ItemNode {
+	t := data.NewTemplateNode(uuid.New(), "Page", "/sitecore/templates/Page", uuid.Nil)
+	t.AddField(data.NewTemplateField(data.RenderingsFieldId, "__Renderings", "layout", data.SharedFields))
+	t.AddField(data.NewTemplateField(data.FinalRenderingsFieldId, "__Final Renderings", "layout", data.VersionedFields))
+
+	item := data.NewItemNode(uuid.New(), "home", t.GetId(), uuid.Nil, uuid.Nil, time.Now(), time.Now())
+	item.SetTemplate(t)
+	if shared != "" {
+		item.AddFieldValue(data.NewFieldValue(data.RenderingsFieldId, item.GetId(), "__Renderings", shared, testLanguage, 1, time.Now(), time.Now(), data.SharedFields))
+	}
+	if final != "" {
+		item.AddFieldValue(data.NewFieldValue(data.FinalRenderingsFieldId, item.GetId(), "__Final Renderings", final, testLanguage, 1, time.Now(), time.Now(), data.VersionedFields))
+	}
+	return item
+}
+
+const testSharedLayout = `<r><d id="{FE5D7FDF-89C0-4D99-9AA3-B5FBD009C9F3}" l="{14030E9F-CE92-49C6-AD87-7D49B50E42EA}"><r id="{2A2BA0F4-3A6F-4A2B-9A5C-0D4E0E6B9C11}" uid="{11111111-1111-1111-1111-111111111111}" ph="main" /></d></r>`
+
+func TestResolveFieldLayoutType(t *testing.T) {
+	item := newLayoutTestItem(testSharedLayout, "")
+	fld := item.GetTemplate().GetField(data.RenderingsFieldId)
+	pkg := &DataPackage{}
+
+	res, err := ResolveField(item.GetFieldValue(data.RenderingsFieldId, testLanguage), fld, item, pkg, FieldSettings{}, BlobSettings{}, testLanguage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetValue() != "" {
+		t.Errorf("expected the layout not to be exported as a value, got %s", res.GetValue())
+	}
+	layout := res.GetLayout()
+	if layout == nil || len(layout.Devices) != 1 || len(layout.Devices[0].Renderings) != 1 {
+		t.Fatalf("expected one device with one rendering, got %+v", layout)
+	}
+	if ph := layout.Devices[0].Renderings[0].Placeholder; ph != "main" {
+		t.Errorf("expected placeholder main, got %s", ph)
+	}
+}
+
+func TestGetFieldHandlerIgnoresCase(t *testing.T) {
+	for _, ft := range []string{"layout", "Layout"} {
+		if reflect.ValueOf(getFieldHandler(ft)).Pointer() != reflect.ValueOf(handleLayout).Pointer() {
+			t.Errorf("%s should get the layout handler", ft)
+		}
+	}
+	if reflect.ValueOf(getFieldHandler("rich text")).Pointer() != reflect.ValueOf(handleRichText).Pointer() {
+		t.Error("rich text should get the rich text handler")
+	}
+	if !isLayoutField(data.NewTemplateField(uuid.New(), "__Renderings", "Layout", data.SharedFields)) {
+		t.Error("Layout should be a layout field")
+	}
+}
+
+func TestResolveItemSharedLayoutOnly(t *testing.T) {
+	item := newLayoutTestItem(testSharedLayout, "")
+	ts := TemplateSettings{Fields: map[string]FieldSettings{"__Final Renderings": {Name: "__Final Renderings"}}}
+
+	gitem := resolveItem(item, &DataPackage{}, ts, BlobSettings{}, testLanguage)
+	if len(gitem.Fields) != 1 || gitem.Fields[0].Layout == nil {
+		t.Fatalf("expected the shared layout as the final layout, got %+v", gitem.Fields)
+	}
+	if n := len(gitem.Fields[0].Layout.Devices[0].Renderings); n != 1 {
+		t.Errorf("expected 1 rendering, got %d", n)
+	}
+
+	// no layout at all is no field
+	gitem = resolveItem(newLayoutTestItem("", ""), &DataPackage{}, ts, BlobSettings{}, testLanguage)
+	if len(gitem.Fields) != 0 {
+		t.Errorf("expected no fields, got %+v", gitem.Fields)
+	}
+}
@@ -0,0 +1,191 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const manifestFilename string = "manifest.json"
+
+type ManifestJson struct {
+	Blobs []ManifestBlobJson `json:"blobs"`
+}
+
+type ManifestBlobJson struct {
+	BlobId   string `json:"blobId"`
+	ItemId   string `json:"itemId"`
+	Filename string `json:"filename"`
+	Language string `json:"language,omitempty"`
+	File     string `json:"file"`
+	Format   string `json:"format"`
+	Length   int    `json:"length"`
+	Hash     string `json:"sha256"`
+
+	Renditions     []ManifestRenditionJson `json:"renditions,omitempty"`
+	RenditionsHash string                  `json:"renditionsHash,omitempty"`
+}
+
+type ManifestRenditionJson struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Length int    `json:"length"`
+	Hash   string `json:"sha256"`
+}
+
+// blob ids and the files they were written to, shared by the blob writers.
+// with the hash layout many blob ids can point to the same file, files has
+// the one that wrote it. rendition files are in there too
+type blobManifest struct {
+	sync.Mutex
+	blobs map[uuid.UUID]ManifestBlobJson
+	files map[string]uuid.UUID
+}
+
+func newBlobManifest() *blobManifest {
+	return &blobManifest{blobs: make(map[uuid.UUID]ManifestBlobJson), files: make(map[string]uuid.UUID)}
+}
+
+func readBlobManifest(dir string) (*blobManifest, error) {
+	m := newBlobManifest()
+
+	buf, err := os.ReadFile(filepath.Join(dir, manifestFilename))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading blob manifest in %s. %w", dir, err)
+	}
+
+	var mj ManifestJson
+	err = json.Unmarshal(buf, &mj)
+	if err != nil {
+		return nil, fmt.Errorf("parsing blob manifest in %s. %w", dir, err)
+	}
+
+	for _, b := range mj.Blobs {
+		id, err := uuid.Parse(b.BlobId)
+		if err != nil {
+			continue
+		}
+		// only keep what's still there
+		if _, err := os.Stat(filepath.Join(dir, b.File)); err != nil {
+			continue
+		}
+		m.blobs[id] = b
+		for _, f := range getManifestFiles(b) {
+			if _, ok := m.files[f]; !ok {
+				m.files[f] = id
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *blobManifest) get(id uuid.UUID) (ManifestBlobJson, bool) {
+	m.Lock()
+	defer m.Unlock()
+	b, ok := m.blobs[id]
+	return b, ok
+}
+
+// returns false when another blob already wrote the file
+func (m *blobManifest) add(id uuid.UUID, b ManifestBlobJson) bool {
+	m.Lock()
+	defer m.Unlock()
+	m.blobs[id] = b
+	if owner, ok := m.files[b.File]; ok && owner != id {
+		return false
+	}
+	for _, f := range getManifestFiles(b) {
+		m.files[f] = id
+	}
+	return true
+}
+
+func (m *blobManifest) remove(id uuid.UUID) {
+	m.Lock()
+	defer m.Unlock()
+	m.removeBlob(id)
+}
+
+// the lock is already held
+func (m *blobManifest) removeBlob(id uuid.UUID) {
+	b, ok := m.blobs[id]
+	if !ok {
+		return
+	}
+	delete(m.blobs, id)
+	for _, f := range getManifestFiles(b) {
+		if m.files[f] == id {
+			delete(m.files, f)
+		}
+	}
+}
+
+// a file that belonged to another blob on a previous run is being
+// written by this one now, so the old entry goes
+func (m *blobManifest) release(file string, id uuid.UUID) {
+	m.Lock()
+	defer m.Unlock()
+	if owner, ok := m.files[file]; ok && owner != id {
+		m.removeBlob(owner)
+		delete(m.files, file)
+	}
+}
+
+func getManifestFiles(b ManifestBlobJson) []string {
+	files := []string{b.File}
+	for _, r := range b.Renditions {
+		files = append(files, r.File)
+	}
+	return files
+}
+
+func (m *blobManifest) hashes() map[uuid.UUID]string {
+	m.Lock()
+	defer m.Unlock()
+	hashes := make(map[uuid.UUID]string)
+	for id, b := range m.blobs {
+		hashes[id] = b.Hash
+	}
+	return hashes
+}
+
+func (m *blobManifest) write(dir string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	mj := ManifestJson{Blobs: []ManifestBlobJson{}}
+	for _, b := range m.blobs {
+		mj.Blobs = append(mj.Blobs, b)
+	}
+	sort.Slice(mj.Blobs, func(i, j int) bool {
+		return mj.Blobs[i].BlobId < mj.Blobs[j].BlobId
+	})
+
+	buf, err := json.MarshalIndent(mj, "", " ")
+	if err != nil {
+		return fmt.Errorf("encoding blob manifest. %w", err)
+	}
+
+	err = os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("couldn't create dir structure %s. %w", dir, err)
+	}
+
+	err = writeFileAtomic(filepath.Join(dir, manifestFilename), func(w io.Writer) error {
+		_, err := w.Write(buf)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("writing blob manifest. %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,488 @@
+package process
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const markdownFormat string = "markdown"
+
+var mdSpaceReg *regexp.Regexp = regexp.MustCompile(`[ \t\r\n\f]+`)
+var mdBlankLineReg *regexp.Regexp = regexp.MustCompile(`\n\s*\n`)
+var mdBlockStartReg *regexp.Regexp = regexp.MustCompile(`^[#>\-+=]`)
+var mdOrderedStartReg *regexp.Regexp = regexp.MustCompile(`^\d+[.)]`)
+var mdEscaper *strings.Replacer = strings.NewReplacer(`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`, `<`, `\<`)
+
+// &copy; in the text, from &amp;copy; in the html, would be a © in markdown
+var mdEntityReg *regexp.Regexp = regexp.MustCompile(`&(#[0-9]+|#[xX][0-9a-fA-F]+|[A-Za-z][A-Za-z0-9]*);`)
+
+// elements that markdown has nothing for, they are kept as html
+var mdRawBlocks map[atom.Atom]bool = map[atom.Atom]bool{
+	atom.Iframe: true, atom.Video: true, atom.Audio: true, atom.Object: true, atom.Embed: true,
+	atom.Form: true, atom.Script: true, atom.Noscript: true, atom.Style: true, atom.Svg: true,
+	atom.Canvas: true, atom.Dl: true, atom.Details: true, atom.Map: true,
+}
+
+var mdRawInlines map[atom.Atom]bool = map[atom.Atom]bool{
+	atom.U: true, atom.Sup: true, atom.Sub: true, atom.Del: true, atom.S: true, atom.Strike: true,
+	atom.Ins: true, atom.Mark: true, atom.Small: true, atom.Abbr: true, atom.Kbd: true,
+}
+
+// elements that only group other blocks, their children are output in their place
+var mdContainers map[atom.Atom]bool = map[atom.Atom]bool{
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true, atom.Footer: true,
+	atom.Main: true, atom.Aside: true, atom.Nav: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Center: true, atom.Body: true, atom.Html: true, atom.Address: true,
+}
+
+var mdBlockElements map[atom.Atom]bool = map[atom.Atom]bool{
+	atom.P: true, atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Blockquote: true, atom.Pre: true, atom.Hr: true, atom.Table: true,
+	atom.Li: true,
+}
+
+type mdBlock struct {
+	text string
+	list bool
+}
+
+// converts rich text html to commonmark. tables use the pipe table extension
+// most static site generators support, unless they have merged cells or block
+// content, then they stay html like anything else without a markdown equivalent
+func htmlToMarkdown(s string) (string, error) {
+	ctx := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(s), ctx)
+	if err != nil {
+		return "", fmt.Errorf("parsing html for markdown. %w", err)
+	}
+
+	blocks := mdBlocks(nodes)
+	if len(blocks) == 0 {
+		return "", nil
+	}
+	return joinMdBlocks(blocks, false) + "\n", nil
+}
+
+func isMdBlock(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	return mdBlockElements[n.DataAtom] || mdContainers[n.DataAtom] || mdRawBlocks[n.DataAtom]
+}
+
+func mdBlocks(nodes []*html.Node) []mdBlock {
+	blocks := []mdBlock{}
+	inline := []*html.Node{}
+
+	flush := func() {
+		if p := mdParagraph(inline); p != "" {
+			blocks = append(blocks, mdBlock{text: p})
+		}
+		inline = []*html.Node{}
+	}
+
+	for _, n := range nodes {
+		if !isMdBlock(n) {
+			inline = append(inline, n)
+			continue
+		}
+
+		flush()
+		blocks = append(blocks, mdElementBlocks(n)...)
+	}
+	flush()
+
+	return blocks
+}
+
+func mdElementBlocks(n *html.Node) []mdBlock {
+	switch {
+	case mdContainers[n.DataAtom]:
+		return mdBlocks(mdChildren(n))
+	case mdRawBlocks[n.DataAtom]:
+		return []mdBlock{{text: mdRawHtml(n)}}
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(n.Data[1:])
+		text := strings.ReplaceAll(mdParagraph(mdChildren(n)), "\\\n", " ")
+		if text == "" {
+			return nil
+		}
+		return []mdBlock{{text: strings.Repeat("#", level) + " " + text}}
+	case atom.P, atom.Li:
+		if p := mdParagraph(mdChildren(n)); p != "" {
+			return []mdBlock{{text: p}}
+		}
+		return nil
+	case atom.Ul, atom.Ol:
+		if l := mdList(n); l != "" {
+			return []mdBlock{{text: l, list: true}}
+		}
+		return nil
+	case atom.Blockquote:
+		inner := joinMdBlocks(mdBlocks(mdChildren(n)), false)
+		if inner == "" {
+			return nil
+		}
+		return []mdBlock{{text: prefixLines(inner, "> ", "> ")}}
+	case atom.Pre:
+		return []mdBlock{{text: mdCodeBlock(n)}}
+	case atom.Hr:
+		return []mdBlock{{text: "---"}}
+	case atom.Table:
+		return []mdBlock{{text: mdTable(n)}}
+	}
+
+	return nil
+}
+
+func joinMdBlocks(blocks []mdBlock, tight bool) string {
+	sb := strings.Builder{}
+	for i, b := range blocks {
+		if i > 0 {
+			if tight && b.list {
+				sb.WriteString("\n")
+			} else {
+				sb.WriteString("\n\n")
+			}
+		}
+		sb.WriteString(b.text)
+	}
+	return sb.String()
+}
+
+func mdChildren(n *html.Node) []*html.Node {
+	list := []*html.Node{}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		list = append(list, c)
+	}
+	return list
+}
+
+func mdParagraph(nodes []*html.Node) string {
+	text := mdInline(nodes)
+	lines := strings.Split(text, "\n")
+	out := []string{}
+	for _, line := range lines {
+		line = strings.TrimLeft(line, " ")
+		if !strings.HasSuffix(line, "\\") {
+			line = strings.TrimRight(line, " ")
+		}
+		out = append(out, line)
+	}
+
+	p := strings.TrimSpace(strings.Join(out, "\n"))
+	p = strings.TrimSuffix(p, "\\")
+	p = strings.TrimSpace(p)
+	// text that would start a heading, quote or list is escaped
+	if mdBlockStartReg.MatchString(p) {
+		p = "\\" + p
+	} else if m := mdOrderedStartReg.FindString(p); m != "" {
+		p = m[:len(m)-1] + "\\" + p[len(m)-1:]
+	}
+	return p
+}
+
+func mdInline(nodes []*html.Node) string {
+	sb := strings.Builder{}
+	for _, n := range nodes {
+		sb.WriteString(mdInlineNode(n))
+	}
+	return sb.String()
+}
+
+func mdInlineNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		text := strings.ReplaceAll(n.Data, "\u00a0", " ")
+		return mdEscape(mdSpaceReg.ReplaceAllString(text, " "))
+	case html.ElementNode:
+	default:
+		return ""
+	}
+
+	if mdRawInlines[n.DataAtom] {
+		inner := mdInline(mdChildren(n))
+		if strings.TrimSpace(inner) == "" {
+			return inner
+		}
+		return mdStartTag(n) + inner + "</" + n.Data + ">"
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		return "\\\n"
+	case atom.Strong, atom.B:
+		return mdWrap("**", mdInline(mdChildren(n)))
+	case atom.Em, atom.I:
+		return mdWrap("*", mdInline(mdChildren(n)))
+	case atom.Code:
+		return mdCodeSpan(mdText(n))
+	case atom.A:
+		inner := mdInline(mdChildren(n))
+		href := mdAttr(n, "href")
+		if href == "" {
+			return inner
+		}
+		if strings.TrimSpace(inner) == "" {
+			inner = mdEscape(href)
+		}
+		return "[" + strings.TrimSpace(inner) + "](" + mdDestination(href, mdAttr(n, "title")) + ")"
+	case atom.Img:
+		src := mdAttr(n, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + mdEscape(mdAttr(n, "alt")) + "](" + mdDestination(src, mdAttr(n, "title")) + ")"
+	}
+
+	if mdRawBlocks[n.DataAtom] {
+		return mdRawHtml(n)
+	}
+
+	// span, font and block elements nested in inline content just give their text
+	inner := mdInline(mdChildren(n))
+	if isMdBlock(n) {
+		return " " + inner + " "
+	}
+	return inner
+}
+
+// emphasis markers can't have space just inside them
+func mdWrap(marker, inner string) string {
+	trimmed := strings.TrimSpace(inner)
+	if trimmed == "" {
+		return inner
+	}
+	lead := inner[:strings.Index(inner, trimmed)]
+	trail := inner[len(lead)+len(trimmed):]
+	return lead + marker + trimmed + marker + trail
+}
+
+func mdCodeSpan(text string) string {
+	fence := "`"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	if strings.HasPrefix(text, "`") || strings.HasSuffix(text, "`") {
+		text = " " + text + " "
+	}
+	return fence + text + fence
+}
+
+func mdCodeBlock(n *html.Node) string {
+	lang := ""
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Code {
+			for _, cls := range strings.Fields(mdAttr(c, "class")) {
+				if strings.HasPrefix(cls, "language-") {
+					lang = strings.TrimPrefix(cls, "language-")
+				}
+			}
+		}
+	}
+
+	text := strings.TrimSuffix(strings.TrimPrefix(mdText(n), "\n"), "\n")
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence + lang + "\n" + text + "\n" + fence
+}
+
+func mdList(n *html.Node) string {
+	ordered := n.DataAtom == atom.Ol
+	num := 1
+	if start, err := strconv.Atoi(mdAttr(n, "start")); err == nil && ordered {
+		num = start
+	}
+
+	items := []string{}
+	loose := false
+	for _, c := range mdChildren(n) {
+		if c.Type != html.ElementNode || c.DataAtom != atom.Li {
+			continue
+		}
+
+		marker := "- "
+		if ordered {
+			marker = strconv.Itoa(num) + ". "
+			num++
+		}
+
+		content := joinMdBlocks(mdBlocks(mdChildren(c)), true)
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, prefixLines(content, marker, indent))
+		loose = loose || strings.Contains(content, "\n\n")
+	}
+
+	if loose {
+		return strings.Join(items, "\n\n")
+	}
+	return strings.Join(items, "\n")
+}
+
+func mdTable(n *html.Node) string {
+	rows := [][]*html.Node{}
+
+	var collect func(p *html.Node)
+	collect = func(p *html.Node) {
+		for _, c := range mdChildren(p) {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				collect(c)
+			case atom.Tr:
+				cells := []*html.Node{}
+				for _, cell := range mdChildren(c) {
+					if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+						cells = append(cells, cell)
+					}
+				}
+				rows = append(rows, cells)
+			}
+		}
+	}
+	collect(n)
+
+	if len(rows) == 0 {
+		return mdRawHtml(n)
+	}
+
+	cols := 0
+	for _, row := range rows {
+		if len(row) > cols {
+			cols = len(row)
+		}
+		for _, cell := range row {
+			if mdSpan(cell, "colspan") || mdSpan(cell, "rowspan") || mdHasComplexContent(cell) {
+				return mdRawHtml(n)
+			}
+		}
+	}
+
+	// pipe tables need a header row, the first row is used whether it's marked as one or not
+	lines := []string{}
+	for i, row := range rows {
+		cells := []string{}
+		for c := 0; c < cols; c++ {
+			text := ""
+			if c < len(row) {
+				text = strings.ReplaceAll(mdParagraph(mdChildren(row[c])), "\\\n", "<br>")
+				text = strings.ReplaceAll(strings.ReplaceAll(text, "|", "\\|"), "\n", " ")
+			}
+			cells = append(cells, text)
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", cols))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func mdSpan(n *html.Node, name string) bool {
+	span, err := strconv.Atoi(mdAttr(n, name))
+	return err == nil && span > 1
+}
+
+func mdHasComplexContent(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		switch c.DataAtom {
+		case atom.Ul, atom.Ol, atom.Table, atom.Pre, atom.Blockquote, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			return true
+		}
+		if mdRawBlocks[c.DataAtom] || mdHasComplexContent(c) {
+			return true
+		}
+	}
+	return false
+}
+
+func mdText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	sb := strings.Builder{}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && c.DataAtom == atom.Br {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(mdText(c))
+	}
+	return sb.String()
+}
+
+func mdAttr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+func mdDestination(dest, title string) string {
+	dest, title = mdEscapeEntities(dest), mdEscapeEntities(title)
+	if strings.ContainsAny(dest, " ()<>") {
+		dest = "<" + strings.NewReplacer("<", "%3C", ">", "%3E").Replace(dest) + ">"
+	}
+	if title != "" {
+		dest += ` "` + strings.ReplaceAll(title, `"`, `\"`) + `"`
+	}
+	return dest
+}
+
+func mdEscape(s string) string {
+	return mdEscapeEntities(mdEscaper.Replace(s))
+}
+
+func mdEscapeEntities(s string) string {
+	return mdEntityReg.ReplaceAllString(s, `\&$1;`)
+}
+
+func mdStartTag(n *html.Node) string {
+	sb := strings.Builder{}
+	sb.WriteString("<" + n.Data)
+	for _, a := range n.Attr {
+		sb.WriteString(" " + a.Key + `="` + html.EscapeString(a.Val) + `"`)
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
+
+// a blank line ends an html block in commonmark, so they are taken out
+func mdRawHtml(n *html.Node) string {
+	var buf bytes.Buffer
+	html.Render(&buf, n)
+	return mdBlankLineReg.ReplaceAllString(strings.TrimSpace(buf.String()), "\n")
+}
+
+func prefixLines(s, first, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		p := rest
+		if i == 0 {
+			p = first
+		}
+		if line == "" {
+			p = strings.TrimRight(p, " ")
+		}
+		lines[i] = p + line
+	}
+	return strings.Join(lines, "\n")
+}
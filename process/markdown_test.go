@@ -0,0 +1,20 @@
+package process
+
+import "testing"
+
+func TestHtmlToMarkdownEscapesEntities(t *testing.T) {
+	for in, expected := range map[string]string{
+		"<p>&amp;copy; 2024</p>":                   "\\&copy; 2024\n",
+		"<p>fish &amp; chips</p>":                  "fish & chips\n",
+		"<p>&amp;#169; and &amp;#xA9;</p>":         "\\&#169; and \\&#xA9;\n",
+		`<p><a href="/a?b=1&amp;c=2">link</a></p>`: "[link](/a?b=1&c=2)\n",
+	} {
+		out, err := htmlToMarkdown(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != expected {
+			t.Errorf("%q expected %q, got %q", in, expected, out)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sort"
@@ -12,7 +13,7 @@ import (
 	"github.com/jasontconnell/sitecore/data"
 )
 
-func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Language, since time.Time) (*DataPackage, error) {
+func ReadAll(ctx context.Context, src Source, protobufLocation string, settings Settings, lang data.Language, since time.Time) (*DataPackage, error) {
 	templateIds := []uuid.UUID{}
 	tfm := make(map[uuid.UUID]bool)
 	for tid := range settings.References {
@@ -25,6 +26,11 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 		templateIds = append(templateIds, tid)
 	}
 
+	for tid := range settings.Datasources {
+		tfm[tid] = true
+		templateIds = append(templateIds, tid)
+	}
+
 	var pitems []data.ItemNode
 	if protobufLocation != "" {
 		var perr error
@@ -36,7 +42,9 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 	}
 
 	log.Println("loading templates")
-	tlist, err := api.LoadTemplatesMergeProtobuf(connstr, pitems)
+	tlist, err := withRetry(ctx, settings.Retry, "loading templates", func() ([]data.TemplateNode, error) {
+		return src.LoadTemplates(pitems)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't load templates %w", err)
 	}
@@ -45,7 +53,9 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 	tm := api.GetTemplateMap(tlist)
 
 	log.Println("loading items")
-	items, err := api.LoadItemsByTemplates(connstr, templateIds)
+	items, err := withRetry(ctx, settings.Retry, "loading items", func() ([]data.ItemNode, error) {
+		return src.LoadItemsByTemplates(templateIds)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("loading items %w", err)
 	}
@@ -54,6 +64,17 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 		items = append(items, pitems...)
 	}
 
+	if hasLayoutFields(settings, tm) {
+		log.Println("loading renderings for layout fields")
+		ritems, err := withRetry(ctx, settings.Retry, "loading renderings", func() ([]data.ItemNode, error) {
+			return src.LoadItemsByTemplates(renderingTemplateIds)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("loading renderings %w", err)
+		}
+		items = append(items, ritems...)
+	}
+
 	log.Println("loaded", len(items), "items")
 	_, m := api.LoadItemMap(items)
 
@@ -76,6 +97,10 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 		joined[ts.TemplateId] = ts
 	}
 
+	for _, ts := range settings.Datasources {
+		joined[ts.TemplateId] = ts
+	}
+
 	fields := []uuid.UUID{}
 	for _, stmp := range joined {
 		t, ok := filtered[stmp.TemplateId]
@@ -84,7 +109,16 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 		}
 
 		for _, sfld := range stmp.Fields {
+			if fid, ok := standardFieldIds[sfld.RefField]; ok {
+				fields = append(fields, fid)
+			}
 			if strings.HasPrefix(sfld.Name, ":") {
+				if !isStandardField(sfld.Name) {
+					return nil, fmt.Errorf("unknown standard field %s in template %s %v", sfld.Name, stmp.Name, stmp.TemplateId)
+				}
+				if fid, ok := standardFieldIds[sfld.Name]; ok {
+					fields = append(fields, fid)
+				}
 				continue
 			}
 			fld := t.FindField(sfld.Name)
@@ -92,6 +126,30 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 				return nil, fmt.Errorf("can't find field %s in template %s %v", sfld.Name, stmp.Name, stmp.TemplateId)
 			}
 			fields = append(fields, fld.GetId())
+			if isLayoutField(fld) {
+				// final layout is merged onto the shared layout
+				fields = append(fields, data.RenderingsFieldId, data.FinalRenderingsFieldId)
+			}
+		}
+	}
+
+	// media size so changed blobs are exported again, and the file path
+	// for media that's on disk
+	for _, stmp := range joined {
+		if t, ok := filtered[stmp.TemplateId]; ok && (t.FindField("Blob") != nil || t.GetField(data.VersionedBlobFieldId) != nil) {
+			if sfld := t.FindField("Size"); sfld != nil {
+				fields = append(fields, sfld.GetId())
+			}
+			if fpfld := t.FindField(filePathFieldName); fpfld != nil {
+				fields = append(fields, fpfld.GetId())
+			}
+		}
+	}
+
+	for _, ts := range settings.Templates {
+		if ts.FollowDatasources {
+			fields = append(fields, data.RenderingsFieldId, data.FinalRenderingsFieldId)
+			break
 		}
 	}
 
@@ -99,6 +157,7 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 	fields = append(fields,
 		data.DisplayNameFieldId,
 		data.CreateDateFieldId,
+		SortOrderFieldId,
 
 		data.BlobFieldId,
 		data.AltFieldId,
@@ -128,11 +187,35 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 	}
 
 	log.Println("loading field values with", len(fields), "fields")
-	fvlist, err := api.LoadFieldValuesTemplates(connstr, fields, templateIds, 30)
+	fvlist, err := withRetry(ctx, settings.Retry, "loading field values", func() ([]data.FieldValueNode, error) {
+		return src.LoadFieldValuesTemplates(fields, templateIds, 30)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("couldn't load filtered field values. %w", err)
 	}
 
+	// the folders above the items are only loaded for their paths, the tree
+	// layout needs their sort order too. values can only be loaded by
+	// template, so only the folders' values are kept
+	if settings.Layout == TreeLayout {
+		folders := getFolderItems(m, settings.Templates, tfm)
+		if len(folders) > 0 {
+			folderTemplateIds := getItemTemplateIds(folders)
+			log.Println("loading sort order for", len(folders), "folders with", len(folderTemplateIds), "templates")
+			flist, err := withRetry(ctx, settings.Retry, "loading folder sort order", func() ([]data.FieldValueNode, error) {
+				return src.LoadFieldValuesTemplates([]uuid.UUID{SortOrderFieldId}, folderTemplateIds, 30)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("couldn't load folder sort order. %w", err)
+			}
+			for _, fv := range flist {
+				if _, ok := folders[fv.GetItemId()]; ok {
+					fvlist = append(fvlist, fv)
+				}
+			}
+		}
+	}
+
 	log.Println("loaded", len(fvlist), "field values")
 	api.AssignFieldValues(m, fvlist)
 
@@ -144,6 +227,10 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 	filteredRefs := filterMap(m, settings.References)
 	log.Println("filtered references map, new item count is", len(filteredRefs))
 
+	log.Println("filtering datasources, current item count is", len(m))
+	filteredDatasources := filterMap(m, settings.Datasources)
+	log.Println("filtered datasources map, new item count is", len(filteredDatasources))
+
 	if since.After(DefaultModTime) {
 		log.Println("filtering for items created or updated after", since)
 		filteredItems = api.FilterItemMapCustom(filteredItems, func(i data.ItemNode) bool {
@@ -170,7 +257,60 @@ func ReadAll(connstr, protobufLocation string, settings Settings, lang data.Lang
 		return reportItems[i].GetName() < reportItems[j].GetName()
 	})
 
-	return &DataPackage{reportItems, filteredItems, filteredRefs}, nil
+	return &DataPackage{ReportItems: reportItems, Items: filteredItems, RefItems: filteredRefs, Datasources: filteredDatasources, Renderings: api.GetRenderingItems(m)}, nil
+}
+
+// the same folders resolveFolders walks, the parents between each item and
+// its template path. ones with a configured template have their values already
+func getFolderItems(m data.ItemMap, tmps map[uuid.UUID]TemplateSettings, tfm map[uuid.UUID]bool) data.ItemMap {
+	folders := make(data.ItemMap)
+	for _, item := range m {
+		ts, ok := tmps[item.GetTemplateId()]
+		if !ok {
+			continue
+		}
+		root := getRootPath(item.GetPath(), ts.Paths)
+		if root == "" {
+			continue
+		}
+		for p := item.GetParent(); p != nil && len(p.GetPath()) >= len(root); p = p.GetParent() {
+			if !tfm[p.GetTemplateId()] {
+				folders[p.GetId()] = p
+			}
+		}
+	}
+	return folders
+}
+
+func getItemTemplateIds(m data.ItemMap) []uuid.UUID {
+	ids := []uuid.UUID{}
+	seen := make(map[uuid.UUID]bool)
+	for _, item := range m {
+		tid := item.GetTemplateId()
+		if seen[tid] || tid == uuid.Nil {
+			continue
+		}
+		seen[tid] = true
+		ids = append(ids, tid)
+	}
+	return ids
+}
+
+func hasLayoutFields(settings Settings, tm data.TemplateMap) bool {
+	for _, m := range []map[uuid.UUID]TemplateSettings{settings.Templates, settings.References, settings.Datasources} {
+		for _, stmp := range m {
+			t, ok := tm[stmp.TemplateId]
+			if !ok {
+				continue
+			}
+			for _, sfld := range stmp.Fields {
+				if fld := t.FindField(sfld.Name); fld != nil && isLayoutField(fld) {
+					return true
+				}
+			}
+		}
+	}
+	return false
 }
 
 func filterMap(m data.ItemMap, tmps map[uuid.UUID]TemplateSettings) data.ItemMap {
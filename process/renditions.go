@@ -0,0 +1,210 @@
+package process
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"path/filepath"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultRenditionQuality   int = 85
+	defaultRenditionMaxPixels int = 50000000
+)
+
+// formats renditions can be written in, only the ones with pure go encoders
+var renditionFormats map[string]string = map[string]string{
+	"jpeg": "image/jpeg",
+	"jpg":  "image/jpeg",
+	"png":  "image/png",
+	"gif":  "image/gif",
+}
+
+// resized copies of image blobs, written next to the original in the same
+// format (raw or base64 xml) as the original
+func writeRenditions(settings WriteSettings, b BlobData, info blobInfo) ([]BlobRenditionXml, []ManifestRenditionJson, error) {
+	if len(settings.Renditions) == 0 || !isRenditionSource(info.ContentType) {
+		return nil, nil, nil
+	}
+
+	// decoding needs 4 bytes or more a pixel, a huge image could take all the
+	// memory so only the header is read first
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b.Data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding image config for renditions. %w", err)
+	}
+	if settings.MaxPixels > 0 && cfg.Width*cfg.Height > settings.MaxPixels {
+		log.Printf("blob %v (item %v) %s no renditions. %dx%d is over the max pixels %d\n", b.BlobId, b.ItemId, b.Path, cfg.Width, cfg.Height, settings.MaxPixels)
+		return nil, nil, nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b.Data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decoding image for renditions. %w", err)
+	}
+
+	var rlist []BlobRenditionXml
+	var mlist []ManifestRenditionJson
+	for _, r := range settings.Renditions {
+		format := getRenditionFormat(r.Format, info.ContentType)
+		dst := resizeImage(img, r.MaxWidth, r.MaxHeight, format == "jpeg")
+
+		var buf bytes.Buffer
+		err := encodeImage(&buf, dst, format, r.Quality)
+		if err != nil {
+			return rlist, mlist, fmt.Errorf("encoding rendition %s. %w", r.Name, err)
+		}
+
+		file := getBlobOutputFile(settings, getRenditionFile(b.File, r.Name, format))
+		path := filepath.Join(settings.BlobLocation, file)
+		if settings.BlobFormat == RawBlobFormat {
+			err = writeFileAtomic(path, func(w io.Writer) error {
+				_, err := w.Write(buf.Bytes())
+				return err
+			})
+		} else {
+			bxml := BlobXml{
+				ItemId:   b.ItemId.String(),
+				BlobId:   b.BlobId.String(),
+				Filename: filepath.Base(getRenditionFile(b.Filename, r.Name, format)),
+				Path:     b.Path,
+				Length:   buf.Len(),
+				Language: b.Language,
+				MimeType: renditionFormats[format],
+				Width:    dst.Bounds().Dx(),
+				Height:   dst.Bounds().Dy(),
+				Data:     &BlobDataXml{Data: base64.StdEncoding.EncodeToString(buf.Bytes())},
+			}
+			err = writeBlobXml(path, bxml)
+		}
+		if err != nil {
+			return rlist, mlist, fmt.Errorf("writing rendition %s. %w", r.Name, err)
+		}
+
+		sum := sha256.Sum256(buf.Bytes())
+		rlist = append(rlist, BlobRenditionXml{Name: r.Name, File: filepath.ToSlash(file), Width: dst.Bounds().Dx(), Height: dst.Bounds().Dy(), Length: buf.Len(), MimeType: renditionFormats[format]})
+		mlist = append(mlist, ManifestRenditionJson{Name: r.Name, File: filepath.ToSlash(file), Length: buf.Len(), Hash: hex.EncodeToString(sum[:])})
+		log.Println("wrote rendition", r.Name, "of", b.File)
+	}
+	return rlist, mlist, nil
+}
+
+// blobs written with different renditions are written again
+func getRenditionsHash(list []RenditionSettings) string {
+	if len(list) == 0 {
+		return ""
+	}
+	h := sha256.New()
+	for _, r := range list {
+		fmt.Fprintf(h, "%s|%d|%d|%s|%d\n", r.Name, r.MaxWidth, r.MaxHeight, r.Format, r.Quality)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// svg doesn't need renditions and there's no pure go decoder for it anyway
+func isRenditionSource(ct string) bool {
+	switch ct {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// same as the original when there's an encoder for it, webp goes to png
+// so transparency is kept
+func getRenditionFormat(format, contentType string) string {
+	if format == "jpg" {
+		return "jpeg"
+	}
+	if format != "" {
+		return format
+	}
+	switch contentType {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/gif":
+		return "gif"
+	}
+	return "png"
+}
+
+// hero.jpg with thumb as png is hero.jpg.thumb.png, the original's extension
+// stays so hero.jpg and hero.png don't write the same rendition
+func getRenditionFile(file, name, format string) string {
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+	return file + "." + sanitizeFilename(name) + ext
+}
+
+// every file a blob's renditions could be written to. without a format it
+// depends on the content, which isn't known until the blob is read
+func getRenditionFiles(list []RenditionSettings, file string) []string {
+	files := []string{}
+	for _, r := range list {
+		formats := []string{getRenditionFormat(r.Format, "")}
+		if r.Format == "" {
+			formats = []string{"jpeg", "gif", "png"}
+		}
+		for _, f := range formats {
+			files = append(files, getRenditionFile(file, r.Name, f))
+		}
+	}
+	return files
+}
+
+// fits the image in the max width and height keeping its aspect ratio, it's
+// never made bigger. jpeg has no transparency so it gets a white background
+func resizeImage(img image.Image, maxWidth, maxHeight int, opaque bool) image.Image {
+	sb := img.Bounds()
+	w, h := sb.Dx(), sb.Dy()
+	scale := 1.0
+	if maxWidth > 0 && w > maxWidth {
+		scale = float64(maxWidth) / float64(w)
+	}
+	if maxHeight > 0 && float64(h)*scale > float64(maxHeight) {
+		scale = float64(maxHeight) / float64(h)
+	}
+
+	dw, dh := int(float64(w)*scale+0.5), int(float64(h)*scale+0.5)
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	if opaque {
+		draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, sb, draw.Over, nil)
+	return dst
+}
+
+func encodeImage(w io.Writer, img image.Image, format string, quality int) error {
+	switch format {
+	case "jpeg":
+		if quality <= 0 || quality > 100 {
+			quality = defaultRenditionQuality
+		}
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+	case "gif":
+		return gif.Encode(w, img, nil)
+	case "png":
+		return png.Encode(w, img)
+	}
+	return fmt.Errorf("unsupported format %s", format)
+}
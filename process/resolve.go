@@ -5,7 +5,9 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jasontconnell/sitecore/data"
 )
 
@@ -13,6 +15,8 @@ const errorlen int = 150
 
 func Resolve(pkg *DataPackage, settings Settings, lang data.Language) ([]Group, error) {
 	gmap := map[string]Group{}
+	exported := make(map[uuid.UUID]bool)
+	nodes := make(map[string][]data.ItemNode)
 	for _, item := range pkg.ReportItems {
 		tsettings, ok := settings.Templates[item.GetTemplateId()]
 		if !ok {
@@ -25,13 +29,30 @@ func Resolve(pkg *DataPackage, settings Settings, lang data.Language) ([]Group,
 			group = Group{Name: gkey}
 		}
 
-		item := resolveItem(item, pkg, tsettings, settings.BlobSettings, lang)
+		node := item
+		item := resolveItem(node, pkg, tsettings, settings.BlobSettings, lang)
+		if tsettings.FollowDatasources {
+			item.Datasources = resolveDatasources(node, pkg, settings, lang)
+			for _, ds := range item.Datasources {
+				item.Blobs = append(item.Blobs, ds.Blobs...)
+			}
+		}
+
 		for _, b := range item.Blobs {
 			group.Blobs = append(group.Blobs, b)
 		}
 
 		group.Items = append(group.Items, item)
 		gmap[group.Name] = group
+		exported[node.GetId()] = true
+		nodes[group.Name] = append(nodes[group.Name], node)
+	}
+
+	// folders between the items and their template path so the tree can be rebuilt
+	for name, list := range nodes {
+		group := gmap[name]
+		group.Folders = resolveFolders(list, settings.Templates[list[0].GetTemplateId()].Paths, exported, lang)
+		gmap[name] = group
 	}
 
 	groups := []Group{}
@@ -41,6 +62,88 @@ func Resolve(pkg *DataPackage, settings Settings, lang data.Language) ([]Group,
 	return groups, nil
 }
 
+// walks up from each item to the template path it's under, any parent
+// that isn't exported is added once as a placeholder
+func resolveFolders(list []data.ItemNode, paths []string, exported map[uuid.UUID]bool, lang data.Language) []Item {
+	folders := []Item{}
+	added := make(map[uuid.UUID]bool)
+	for _, node := range list {
+		root := getRootPath(node.GetPath(), paths)
+		if root == "" {
+			continue
+		}
+
+		for p := node.GetParent(); p != nil && len(p.GetPath()) >= len(root); p = p.GetParent() {
+			if exported[p.GetId()] || added[p.GetId()] {
+				continue
+			}
+			added[p.GetId()] = true
+
+			folder := Item{ID: p.GetId().String(), Name: p.GetName(), Path: p.GetPath(), ParentId: p.GetParentId().String(), Placeholder: true}
+			folder.SortOrder, _ = resolveStandardField(p, SortOrderOutputField, lang)
+			folders = append(folders, folder)
+		}
+	}
+	return folders
+}
+
+// the longest configured path the item is under
+func getRootPath(itemPath string, paths []string) string {
+	root := ""
+	lower := strings.ToLower(itemPath)
+	for _, p := range paths {
+		p = strings.TrimSuffix(p, "/")
+		if strings.HasPrefix(p, "-") || p == "" {
+			continue
+		}
+		if strings.HasPrefix(lower, strings.ToLower(p)+"/") && len(p) > len(root) {
+			root = p
+		}
+	}
+	return root
+}
+
+// datasources come from the page's final layout, each one is resolved with
+// the field settings for its template in datasourceTemplates
+func resolveDatasources(item data.ItemNode, pkg *DataPackage, settings Settings, lang data.Language) []Item {
+	layout, err := getItemLayout(item, true, pkg, lang)
+	if err != nil {
+		log.Printf("couldn't get layout for datasources on item %v. %v\n", item.GetId(), err)
+		return nil
+	}
+	if layout == nil {
+		return nil
+	}
+
+	list := []Item{}
+	seen := make(map[uuid.UUID]bool)
+	for _, d := range layout.Devices {
+		for _, r := range d.Renderings {
+			if r.DataSource == nil || r.DataSource.ID == "" {
+				continue
+			}
+
+			id, err := uuid.Parse(r.DataSource.ID)
+			if err != nil || seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			ds, ok := pkg.Datasources[id]
+			if !ok {
+				log.Printf("datasource %v on item %v is not in the datasource templates. skipping\n", id, item.GetId())
+				continue
+			}
+
+			dsettings := settings.Datasources[ds.GetTemplateId()]
+			ditem := resolveItem(ds, pkg, dsettings, settings.BlobSettings, lang)
+			ditem.TypeName = dsettings.Name
+			list = append(list, ditem)
+		}
+	}
+	return list
+}
+
 func resolveReferenceItem(item data.ItemNode, pkg *DataPackage, field string, bsettings BlobSettings, lang data.Language) (Item, error) {
 	if item == nil {
 		return Item{}, fmt.Errorf("item is nil")
@@ -48,7 +151,11 @@ func resolveReferenceItem(item data.ItemNode, pkg *DataPackage, field string, bs
 	gitem := Item{ID: item.GetId().String(), Name: item.GetName(), Path: item.GetPath(), Fields: []Field{}}
 
 	var err error
-	if !strings.HasPrefix(field, ":") {
+	if strings.HasPrefix(field, ":") {
+		if val, ok := resolveStandardField(item, field, lang); ok {
+			gitem.Fields = append(gitem.Fields, Field{Name: field, Value: val})
+		}
+	} else {
 		itmp := item.GetTemplate()
 
 		fld := itmp.FindField(field)
@@ -76,11 +183,7 @@ func resolveReferenceItem(item data.ItemNode, pkg *DataPackage, field string, bs
 		gfld.CData = result.IsHtml()
 
 		for _, blob := range result.GetBlobs() {
-			b := Blob{ItemId: blob.GetItemId(), BlobId: blob.GetBlobId(), Filename: blob.GetName() + "." + blob.GetExt(), Path: blob.GetPath()}
-			for _, attr := range blob.GetAttrs() {
-				b.Attrs = append(b.Attrs, Attr{Name: attr.Name, Value: attr.Value})
-			}
-			gitem.Blobs = append(gitem.Blobs, b)
+			gitem.Blobs = append(gitem.Blobs, getBlob(blob, bsettings))
 		}
 
 		gitem.Fields = append(gitem.Fields, gfld)
@@ -93,9 +196,32 @@ func resolveReferenceItem(item data.ItemNode, pkg *DataPackage, field string, bs
 	return gitem, err
 }
 
+// blobs the filters leave out are still referenced, with the reason
+func getBlob(blob BlobResult, bsettings BlobSettings) Blob {
+	b := Blob{ItemId: blob.GetItemId(), BlobId: blob.GetBlobId(), Filename: blob.GetName() + "." + blob.GetExt(), Path: blob.GetPath(), MimeType: blob.GetMimeType(), Size: blob.GetSize(), Language: blob.GetLanguage(), FilePath: blob.GetFilePath()}
+	for _, attr := range blob.GetAttrs() {
+		b.Attrs = append(b.Attrs, Attr{Name: attr.Name, Value: attr.Value})
+	}
+	b.SkipReason = getBlobSkipReason(b, bsettings.Filter)
+	return b
+}
+
 func resolveItem(item data.ItemNode, pkg *DataPackage, tsetting TemplateSettings, bsettings BlobSettings, lang data.Language) Item {
 	gitem := Item{ID: item.GetId().String(), Name: item.GetName(), Path: item.GetPath(), Fields: []Field{}}
+	gitem.ParentId = item.GetParentId().String()
+	gitem.SortOrder, _ = resolveStandardField(item, SortOrderOutputField, lang)
 	for _, fs := range tsetting.Fields {
+		if strings.HasPrefix(fs.Name, ":") {
+			if val, ok := resolveStandardField(item, fs.Name, lang); ok {
+				fnm := fs.Name
+				if fs.Alias != "" {
+					fnm = fs.Alias
+				}
+				gitem.Fields = append(gitem.Fields, Field{Name: fnm, Value: val})
+			}
+			continue
+		}
+
 		itmp := item.GetTemplate()
 		stdval := itmp.GetStandardValues()
 
@@ -110,6 +236,12 @@ func resolveItem(item data.ItemNode, pkg *DataPackage, tsetting TemplateSettings
 				fv = stdval.GetFieldValue(fld.GetId(), lang)
 			}
 
+			// the final layout is often all shared layout, with nothing on
+			// __Final Renderings, so layout fields are always merged
+			if fv == nil && isLayoutField(fld) {
+				fv = data.NewFieldValue(fld.GetId(), item.GetId(), fld.GetName(), "", lang, 0, time.Time{}, time.Time{}, data.VersionedFields)
+			}
+
 			// nil here means it has no value, it's not an error
 			if fv == nil {
 				continue
@@ -134,16 +266,21 @@ func resolveItem(item data.ItemNode, pkg *DataPackage, tsetting TemplateSettings
 		gfld.CData = result.IsHtml()
 
 		for _, blob := range result.GetBlobs() {
-			b := Blob{ItemId: blob.GetItemId(), BlobId: blob.GetBlobId(), Filename: blob.GetName() + "." + blob.GetExt(), Path: blob.GetPath()}
-			for _, attr := range blob.GetAttrs() {
-				b.Attrs = append(b.Attrs, Attr{Name: attr.Name, Value: attr.Value})
-			}
-			gitem.Blobs = append(gitem.Blobs, b)
+			gitem.Blobs = append(gitem.Blobs, getBlob(blob, bsettings))
 		}
 
+		gitem.ItemRefs = append(gitem.ItemRefs, result.GetItemRefs()...)
+		gitem.Unresolved = append(gitem.Unresolved, result.GetUnresolved()...)
+
 		for _, ref := range result.GetReferences() {
 			gfld.Refs = append(gfld.Refs, ref)
 		}
+		gfld.Link = result.GetLink()
+		gfld.Image = result.GetImage()
+		gfld.Layout = result.GetLayout()
+		if gfld.Layout == nil && fv.GetValue() == "" && isLayoutField(fld) {
+			continue
+		}
 
 		gitem.Fields = append(gitem.Fields, gfld)
 	}
@@ -0,0 +1,141 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRetryAttempts int           = 3
+	defaultRetryDelay    time.Duration = time.Second
+	defaultRetryMaxDelay time.Duration = 30 * time.Second
+	defaultMaxAbandoned  int           = 2
+)
+
+var errCallTimedOut error = errors.New("timed out")
+
+// timed out calls that are still running, across all the readers
+var abandonedCalls atomic.Int32
+
+// calls fn until it works or the attempts run out, waiting twice as long
+// (give or take some jitter) between each try. each try gets the timeout,
+// and once too many timed out tries are still running the next one is
+// waited for instead of retried
+func withRetry[T any](ctx context.Context, rs RetrySettings, name string, fn func() (T, error)) (T, error) {
+	var zero T
+	attempts := rs.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			wait := getJitter(getRetryDelay(rs, i))
+			log.Printf("%s failed, retrying in %v (attempt %d of %d). %v\n", name, wait, i+1, attempts, err)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return zero, fmt.Errorf("%s cancelled. %w", name, ctx.Err())
+			}
+		}
+
+		var v T
+		v, err = callWithTimeout(ctx, rs, fn)
+		if err == nil {
+			return v, nil
+		}
+		if ctx.Err() != nil {
+			return zero, fmt.Errorf("%s cancelled. %w", name, ctx.Err())
+		}
+	}
+	return zero, fmt.Errorf("%s failed after %d attempts. %w", name, attempts, err)
+}
+
+// the sitecore api doesn't take a context, so a call that times out is
+// left to finish on its own and its result is thrown away. only so many are
+// left running at once, after that a call that times out is waited for so a
+// slow server doesn't get more and more queries
+func callWithTimeout[T any](ctx context.Context, rs RetrySettings, fn func() (T, error)) (T, error) {
+	tctx := ctx
+	if rs.Timeout > 0 {
+		var cancel context.CancelFunc
+		tctx, cancel = context.WithTimeout(ctx, rs.Timeout)
+		defer cancel()
+	}
+
+	type result struct {
+		v   T
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		ch <- result{v: v, err: err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.v, r.err
+	case <-tctx.Done():
+	}
+
+	if n := abandonedCalls.Add(1); int(n) > getMaxAbandoned(rs) {
+		abandonedCalls.Add(-1)
+		log.Println("too many timed out calls are still running, waiting for this one")
+		r := <-ch
+		return r.v, r.err
+	}
+	go func() {
+		<-ch
+		abandonedCalls.Add(-1)
+	}()
+
+	var zero T
+	if ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+	return zero, fmt.Errorf("%w after %v", errCallTimedOut, rs.Timeout)
+}
+
+// settings that weren't read from the config get the default, negative
+// always waits
+func getMaxAbandoned(rs RetrySettings) int {
+	if rs.MaxAbandoned == 0 {
+		return defaultMaxAbandoned
+	}
+	if rs.MaxAbandoned < 0 {
+		return 0
+	}
+	return rs.MaxAbandoned
+}
+
+// the wait before a retry, before the jitter. the first retry is 1
+func getRetryDelay(rs RetrySettings, retry int) time.Duration {
+	delay := rs.Delay
+	for i := 1; i < retry; i++ {
+		delay *= 2
+		if rs.MaxDelay > 0 && delay > rs.MaxDelay {
+			return rs.MaxDelay
+		}
+	}
+	if rs.MaxDelay > 0 && delay > rs.MaxDelay {
+		return rs.MaxDelay
+	}
+	return delay
+}
+
+// somewhere between half the delay and the whole delay so retries from
+// the blob readers don't all hit the database at once
+func getJitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := int64(d / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
@@ -0,0 +1,207 @@
+package process
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/scexport/conf"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+var errFlaky = errors.New("flaky source failed")
+
+// fails the first failures calls to each method, then works
+type flakySource struct {
+	sync.Mutex
+	failures int
+	calls    int
+}
+
+func (s *flakySource) call() error {
+	s.Lock()
+	defer s.Unlock()
+	s.calls++
+	if s.calls <= s.failures {
+		return errFlaky
+	}
+	return nil
+}
+
+func (s *flakySource) LoadTemplates(pitems []data.ItemNode) ([]data.TemplateNode, error) {
+	return nil, s.call()
+}
+
+func (s *flakySource) LoadItemsByTemplates(templateIds []uuid.UUID) ([]data.ItemNode, error) {
+	return nil, s.call()
+}
+
+func (s *flakySource) LoadFieldValuesTemplates(fieldIds, templateIds []uuid.UUID, c int) ([]data.FieldValueNode, error) {
+	return nil, s.call()
+}
+
+func (s *flakySource) LoadBlob(id uuid.UUID) (data.Blob, error) {
+	if err := s.call(); err != nil {
+		return nil, err
+	}
+	return data.NewBlob(id, []byte("blob data")), nil
+}
+
+func TestWithRetrySucceedsAfterFailures(t *testing.T) {
+	src := &flakySource{failures: 2}
+	rs := RetrySettings{Attempts: 3, Delay: 10 * time.Millisecond}
+
+	start := time.Now()
+	blob, err := withRetry(context.Background(), rs, "loading blob", func() (data.Blob, error) {
+		return src.LoadBlob(uuid.New())
+	})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if string(blob.GetData()) != "blob data" {
+		t.Errorf("unexpected blob data %q", blob.GetData())
+	}
+	if src.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", src.calls)
+	}
+
+	// at least half of 10ms then half of 20ms with the jitter
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected the retries to back off, only took %v", elapsed)
+	}
+}
+
+func TestWithRetryGivesUp(t *testing.T) {
+	src := &flakySource{failures: 10}
+	rs := RetrySettings{Attempts: 3, Delay: time.Millisecond}
+
+	_, err := withRetry(context.Background(), rs, "loading items", func() ([]data.ItemNode, error) {
+		return src.LoadItemsByTemplates(nil)
+	})
+	if !errors.Is(err, errFlaky) {
+		t.Fatalf("expected the source error, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "loading items failed after 3 attempts") {
+		t.Errorf("unexpected error %v", err)
+	}
+	if src.calls != 3 {
+		t.Errorf("expected 3 calls, got %d", src.calls)
+	}
+}
+
+func TestWithRetryCancelled(t *testing.T) {
+	src := &flakySource{failures: 10}
+	rs := RetrySettings{Attempts: 5, Delay: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := withRetry(ctx, rs, "loading templates", func() ([]data.TemplateNode, error) {
+		return src.LoadTemplates(nil)
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+	if src.calls != 1 {
+		t.Errorf("expected 1 call before the cancel, got %d", src.calls)
+	}
+}
+
+func TestGetRetryDelay(t *testing.T) {
+	rs := RetrySettings{Delay: time.Second, MaxDelay: 3 * time.Second}
+	for retry, expected := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 3 * time.Second, 10: 3 * time.Second} {
+		if d := getRetryDelay(rs, retry); d != expected {
+			t.Errorf("retry %d expected %v, got %v", retry, expected, d)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if j := getJitter(time.Second); j < time.Second/2 || j > time.Second {
+			t.Fatalf("jitter %v is outside half to all of the delay", j)
+		}
+	}
+}
+
+func TestProcessBlobsRetriesSource(t *testing.T) {
+	src := &flakySource{failures: 1}
+	ws := WriteSettings{ContentFormat: "xml", BlobLocation: t.TempDir(), BlobFormat: RawBlobFormat, Retry: RetrySettings{Attempts: 2, Delay: time.Millisecond}}
+	g := Group{Blobs: []Blob{{BlobId: uuid.New(), ItemId: uuid.New(), Filename: "file.txt", Path: "/sitecore/media library/file"}}}
+
+	res := ProcessBlobs(context.Background(), src, []Group{g}, ws)
+	if res.Written != 1 || res.Failed != 0 {
+		t.Errorf("expected the blob to be written after a retry, got %+v", res)
+	}
+	if src.calls != 2 {
+		t.Errorf("expected 2 calls, got %d", src.calls)
+	}
+}
+
+func TestWithRetryRetriesTimeouts(t *testing.T) {
+	// not from getRetrySettings, the timeout still has to work
+	rs := RetrySettings{Attempts: 3, Delay: time.Millisecond, Timeout: 10 * time.Millisecond, MaxAbandoned: 1}
+
+	release := make(chan struct{})
+	defer waitAbandoned(t, release)
+
+	// only the first call hangs
+	var calls int32
+	v, err := withRetry(context.Background(), rs, "loading blob", func() (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-release
+		}
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Fatalf("expected the retry to work, got %v %v", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected the timed out call to be retried, got %d calls", n)
+	}
+}
+
+func TestWithRetryWaitsOverMaxAbandoned(t *testing.T) {
+	rs, err := getRetrySettings(conf.RetrySettings{Attempts: 3, Delay: "1ms", Timeout: "10ms", MaxAbandoned: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	release := make(chan struct{})
+	defer waitAbandoned(t, release)
+
+	// every call hangs until it's released, the second is waited for
+	var calls int32
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	v, err := withRetry(context.Background(), rs, "loading field values", func() (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return 1, nil
+	})
+	if err != nil || v != 1 {
+		t.Errorf("expected the call to be waited for, got %v %v", v, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("expected one timed out call and one waited for, got %d calls", n)
+	}
+}
+
+// abandoned calls are counted across tests, so they have to finish
+func waitAbandoned(t *testing.T, release chan struct{}) {
+	select {
+	case <-release:
+	default:
+		close(release)
+	}
+	for i := 0; abandonedCalls.Load() > 0; i++ {
+		if i == 100 {
+			t.Fatal("abandoned calls didn't finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,168 @@
+package process
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+const mediaLibraryPath string = "/sitecore/media library"
+
+var linkRteReg *regexp.Regexp = regexp.MustCompile(`(?i)\/?~\/link\.aspx\?_id=\{?([a-f0-9\-]{32,36})\}?(?:&(?:amp;)?_z=z)?`)
+
+// the whitespace before the name keeps data-src and the like from matching
+var mediaAttrReg *regexp.Regexp = regexp.MustCompile(`(?i)(\s)(src|href|srcset)(\s*=\s*)("[^"]*"|'[^']*')`)
+var mediaUrlReg *regexp.Regexp = regexp.MustCompile(`(?i)^(?:https?:\/\/[^\/]+)?\/?[-~]\/media\/([^?#]+)`)
+var mediaIdReg *regexp.Regexp = regexp.MustCompile(`(?i)^\{?([a-f0-9]{32}|[a-f0-9]{8}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{4}-[a-f0-9]{12})\}?$`)
+
+// media urls show up in src, href and srcset, with or without a leading / or ~,
+// either by id (-/media/ABCD....ashx) or by media library path (-/media/images/foo.jpg).
+// they are all replaced with blobref:<media item id>, keeping the query string
+// since it has the size and language. urls that can't be resolved are left as
+// is and reported
+func replaceRteMedia(str string, fv data.FieldValueNode, pkg *DataPackage, bsetting BlobSettings, lang data.Language) (string, []BlobResult, []Unresolved) {
+	blobs := []BlobResult{}
+	unresolved := []Unresolved{}
+	seen := make(map[uuid.UUID]bool)
+
+	replace := func(u string) string {
+		list, ismedia, err := resolveMediaUrl(u, pkg, bsetting, lang)
+		if !ismedia {
+			return u
+		}
+		if err != nil {
+			log.Printf("couldn't resolve media url in rte field %s %v url %s. %v\n", fv.GetName(), fv.GetItemId(), u, err)
+			unresolved = append(unresolved, Unresolved{Field: fv.GetName(), Value: u, Reason: err.Error()})
+			return u
+		}
+
+		id := list[0].GetItemId()
+		if !seen[id] {
+			seen[id] = true
+			blobs = append(blobs, list...)
+		}
+		return "blobref:" + id.String() + getMediaUrlQuery(u)
+	}
+
+	str = mediaAttrReg.ReplaceAllStringFunc(str, func(m string) string {
+		g := mediaAttrReg.FindStringSubmatch(m)
+		space, attr, eq, quoted := g[1], g[2], g[3], g[4]
+		q := quoted[:1]
+		val := quoted[1 : len(quoted)-1]
+
+		if strings.EqualFold(attr, "srcset") {
+			parts := strings.Split(val, ",")
+			for i, part := range parts {
+				fields := strings.Fields(part)
+				if len(fields) == 0 {
+					continue
+				}
+				fields[0] = replace(fields[0])
+				parts[i] = strings.Join(fields, " ")
+			}
+			val = strings.Join(parts, ", ")
+		} else {
+			val = replace(strings.TrimSpace(val))
+		}
+
+		return space + attr + eq + q + val + q
+	})
+
+	return str, blobs, unresolved
+}
+
+// ?h=200&w=300&la=en, and any #fragment after it
+func getMediaUrlQuery(u string) string {
+	if i := strings.IndexAny(u, "?#"); i != -1 {
+		return u[i:]
+	}
+	return ""
+}
+
+// returns whether the url is a sitecore media url at all, and the blobs if it resolves
+func resolveMediaUrl(u string, pkg *DataPackage, bsetting BlobSettings, lang data.Language) ([]BlobResult, bool, error) {
+	m := mediaUrlReg.FindStringSubmatch(u)
+	if len(m) != 2 {
+		return nil, false, nil
+	}
+
+	rest := strings.TrimSuffix(m[1], "/")
+	rest = strings.TrimSuffix(rest, path.Ext(rest))
+
+	var mediaId uuid.UUID
+	if g := mediaIdReg.FindStringSubmatch(rest); len(g) == 2 {
+		id, err := uuid.Parse(g[1])
+		if err != nil {
+			return nil, true, fmt.Errorf("couldn't parse media id %s. %w", g[1], err)
+		}
+		mediaId = id
+	} else {
+		media := findMediaByPath(pkg, rest)
+		if media == nil {
+			return nil, true, fmt.Errorf("media library path not found %s", rest)
+		}
+		mediaId = media.GetId()
+	}
+
+	blobs, err := extractBlob(mediaId, pkg, bsetting, lang)
+	if err != nil {
+		return nil, true, err
+	}
+	return blobs, true, nil
+}
+
+// media urls are the media library path with spaces as - or %20, sitecore
+// doesn't store which one so try both
+func findMediaByPath(pkg *DataPackage, p string) data.ItemNode {
+	decoded, err := url.PathUnescape(p)
+	if err != nil {
+		decoded = p
+	}
+
+	candidates := []string{decoded, strings.ReplaceAll(decoded, "-", " ")}
+	for _, c := range candidates {
+		if item := findItemByPath(pkg, mediaLibraryPath+"/"+c); item != nil {
+			return item
+		}
+	}
+	return nil
+}
+
+// dynamic links look like ~/link.aspx?_id=ABCD...&amp;_z=z, sometimes with a
+// leading /, and only work inside sitecore. the id is all that's needed for
+// the new cms
+func replaceRteLinks(str string, fv data.FieldValueNode, pkg *DataPackage) (string, []ItemRef) {
+	refs := []ItemRef{}
+	seen := make(map[uuid.UUID]bool)
+
+	str = linkRteReg.ReplaceAllStringFunc(str, func(m string) string {
+		g := linkRteReg.FindStringSubmatch(m)
+		id, err := uuid.Parse(g[1])
+		if err != nil {
+			log.Printf("couldn't parse link id in rte field %s %v value %s. leaving as is\n", fv.GetName(), fv.GetItemId(), g[1])
+			return m
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ref := ItemRef{ItemId: id}
+			if target := findItem(pkg, id); target != nil {
+				ref.Name = target.GetName()
+				ref.Path = target.GetPath()
+			} else {
+				log.Printf("link target not found in rte field %s %v value %v\n", fv.GetName(), fv.GetItemId(), id)
+			}
+			refs = append(refs, ref)
+		}
+
+		return "itemref:" + id.String()
+	})
+
+	return str, refs
+}
@@ -0,0 +1,27 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+func TestReplaceRteLinks(t *testing.T) {
+	id := uuid.MustParse("0de95ae4-41ab-4d01-9eb0-67441b7c2450")
+	fv := data.NewFieldValue(uuid.New(), uuid.New(), "Body", "", testLanguage, 1, time.Now(), time.Now(), data.VersionedFields)
+	for in, expected := range map[string]string{
+		`<a href="~/link.aspx?_id=0DE95AE441AB4D019EB067441B7C2450&amp;_z=z">a</a>`:    `<a href="itemref:` + id.String() + `">a</a>`,
+		`<a href="/~/link.aspx?_id=0DE95AE441AB4D019EB067441B7C2450&amp;_z=z">a</a>`:   `<a href="itemref:` + id.String() + `">a</a>`,
+		`<a href="/~/link.aspx?_id={0DE95AE4-41AB-4D01-9EB0-67441B7C2450}&_z=z">a</a>`: `<a href="itemref:` + id.String() + `">a</a>`,
+	} {
+		out, refs := replaceRteLinks(in, fv, &DataPackage{})
+		if out != expected {
+			t.Errorf("%s expected %s, got %s", in, expected, out)
+		}
+		if len(refs) != 1 || refs[0].ItemId != id {
+			t.Errorf("%s expected a ref to %v, got %+v", in, id, refs)
+		}
+	}
+}
@@ -2,6 +2,9 @@ package process
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jasontconnell/scexport/conf"
@@ -14,18 +17,38 @@ func GetSettings(cfg conf.ExportSettings) (Settings, error) {
 	rmap := make(map[uuid.UUID]TemplateSettings)
 	for _, ref := range cfg.ReferenceTemplates {
 		id := api.MustParseUUID(ref.TemplateId)
-		r := TemplateSettings{Name: ref.Name, Paths: ref.Paths, TemplateId: id, Fields: getFieldSettingsMap(ref.Fields)}
+		fields, err := getFieldSettingsMap(ref.Fields)
+		if err != nil {
+			return Settings{}, fmt.Errorf("reference template %s. %w", ref.Name, err)
+		}
+		r := TemplateSettings{Name: ref.Name, Paths: ref.Paths, TemplateId: id, Fields: fields}
 		rmap[id] = r
 	}
 
+	dmap := make(map[uuid.UUID]TemplateSettings)
+	for _, ds := range cfg.DatasourceTemplates {
+		id := api.MustParseUUID(ds.TemplateId)
+		fields, err := getFieldSettingsMap(ds.Fields)
+		if err != nil {
+			return Settings{}, fmt.Errorf("datasource template %s. %w", ds.Name, err)
+		}
+		dmap[id] = TemplateSettings{Name: ds.Name, Paths: ds.Paths, TemplateId: id, Fields: fields}
+	}
+
 	for _, tscfg := range cfg.Templates {
 		id := api.MustParseUUID(tscfg.TemplateId)
+		fields, err := getFieldSettingsMap(tscfg.Fields)
+		if err != nil {
+			return Settings{}, fmt.Errorf("template %s. %w", tscfg.Name, err)
+		}
 
 		settings := TemplateSettings{
 			TemplateId: id,
 			Name:       tscfg.Name,
 			Paths:      tscfg.Paths,
-			Fields:     getFieldSettingsMap(tscfg.Fields),
+			Fields:     fields,
+
+			FollowDatasources: tscfg.FollowDatasources,
 		}
 
 		tsmap[id] = settings
@@ -40,10 +63,67 @@ func GetSettings(cfg conf.ExportSettings) (Settings, error) {
 		bsettings.CustomFields = append(bsettings.CustomFields, uid)
 	}
 
-	return Settings{Templates: tsmap, References: rmap, BlobSettings: bsettings}, nil
+	names := make(map[string]bool)
+	for _, r := range cfg.BlobSettings.Renditions {
+		if r.Name == "" || names[strings.ToLower(r.Name)] {
+			return Settings{}, fmt.Errorf("renditions need a unique name. %s", r.Name)
+		}
+		names[strings.ToLower(r.Name)] = true
+		if _, ok := renditionFormats[strings.ToLower(r.Format)]; r.Format != "" && !ok {
+			return Settings{}, fmt.Errorf("rendition %s format %s isn't supported", r.Name, r.Format)
+		}
+		if r.MaxWidth <= 0 && r.MaxHeight <= 0 {
+			return Settings{}, fmt.Errorf("rendition %s needs a max width or height", r.Name)
+		}
+		bsettings.Renditions = append(bsettings.Renditions, RenditionSettings{Name: r.Name, MaxWidth: r.MaxWidth, MaxHeight: r.MaxHeight, Format: strings.ToLower(r.Format), Quality: r.Quality})
+	}
+
+	// a negative max turns the limit off
+	bsettings.MaxPixels = cfg.BlobSettings.MaxPixels
+	if bsettings.MaxPixels == 0 {
+		bsettings.MaxPixels = defaultRenditionMaxPixels
+	}
+
+	filter, err := getBlobFilter(cfg.BlobSettings)
+	if err != nil {
+		return Settings{}, fmt.Errorf("blob settings. %w", err)
+	}
+	bsettings.Filter = filter
+
+	retry, err := getRetrySettings(cfg.Retry)
+	if err != nil {
+		return Settings{}, fmt.Errorf("retry settings. %w", err)
+	}
+
+	return Settings{Templates: tsmap, References: rmap, Datasources: dmap, BlobSettings: bsettings, Retry: retry, Layout: cfg.Output.Layout}, nil
 }
 
-func getFieldSettingsMap(list []conf.ExportField) map[string]FieldSettings {
+func getRetrySettings(cfg conf.RetrySettings) (RetrySettings, error) {
+	rs := RetrySettings{Attempts: cfg.Attempts, Delay: defaultRetryDelay, MaxDelay: defaultRetryMaxDelay, MaxAbandoned: cfg.MaxAbandoned}
+	if rs.Attempts == 0 {
+		rs.Attempts = defaultRetryAttempts
+	}
+	if rs.MaxAbandoned == 0 {
+		rs.MaxAbandoned = defaultMaxAbandoned
+	}
+
+	for _, d := range []struct {
+		val string
+		dur *time.Duration
+	}{{cfg.Delay, &rs.Delay}, {cfg.MaxDelay, &rs.MaxDelay}, {cfg.Timeout, &rs.Timeout}} {
+		if d.val == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.val)
+		if err != nil {
+			return rs, fmt.Errorf("couldn't parse duration %s. %w", d.val, err)
+		}
+		*d.dur = dur
+	}
+	return rs, nil
+}
+
+func getFieldSettingsMap(list []conf.ExportField) (map[string]FieldSettings, error) {
 	m := make(map[string]FieldSettings)
 	for _, fld := range list {
 		key := fld.Name
@@ -51,11 +131,46 @@ func getFieldSettingsMap(list []conf.ExportField) map[string]FieldSettings {
 			key += ":" + fld.Alias
 		}
 
+		transforms, err := getTransformSettings(fld.Transforms)
+		if err != nil {
+			return nil, fmt.Errorf("field %s. %w", fld.Name, err)
+		}
+
 		m[key] = FieldSettings{
-			Name:     fld.Name,
-			Alias:    fld.Alias,
-			RefField: fld.RefField,
+			Name:       fld.Name,
+			Alias:      fld.Alias,
+			RefField:   fld.RefField,
+			Source:     fld.Source,
+			Format:     fld.Format,
+			Transforms: transforms,
+		}
+	}
+	return m, nil
+}
+
+func getTransformSettings(list []conf.TransformSettings) ([]TransformSettings, error) {
+	transforms := []TransformSettings{}
+	for _, t := range list {
+		if !isTransform(t.Name) {
+			return nil, fmt.Errorf("unknown transform %s", t.Name)
+		}
+
+		ts := TransformSettings{Name: t.Name, Tags: make(map[string]bool), Attributes: make(map[string]bool), Replace: t.Replace}
+		for _, tag := range t.Tags {
+			ts.Tags[strings.ToLower(tag)] = true
+		}
+		for _, attr := range t.Attributes {
+			ts.Attributes[strings.ToLower(attr)] = true
+		}
+
+		if t.Name == replaceTransform {
+			reg, err := regexp.Compile(t.Find)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't compile replace transform %s. %w", t.Find, err)
+			}
+			ts.Find = reg
 		}
+		transforms = append(transforms, ts)
 	}
-	return m
+	return transforms, nil
 }
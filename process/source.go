@@ -0,0 +1,40 @@
+package process
+
+import (
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/api"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+// everything that's read from the database goes through here, so the
+// retries can be tried against a source that fails
+type Source interface {
+	LoadTemplates(pitems []data.ItemNode) ([]data.TemplateNode, error)
+	LoadItemsByTemplates(templateIds []uuid.UUID) ([]data.ItemNode, error)
+	LoadFieldValuesTemplates(fieldIds, templateIds []uuid.UUID, c int) ([]data.FieldValueNode, error)
+	LoadBlob(id uuid.UUID) (data.Blob, error)
+}
+
+type sqlSource struct {
+	connstr string
+}
+
+func NewSqlSource(connstr string) Source {
+	return sqlSource{connstr: connstr}
+}
+
+func (s sqlSource) LoadTemplates(pitems []data.ItemNode) ([]data.TemplateNode, error) {
+	return api.LoadTemplatesMergeProtobuf(s.connstr, pitems)
+}
+
+func (s sqlSource) LoadItemsByTemplates(templateIds []uuid.UUID) ([]data.ItemNode, error) {
+	return api.LoadItemsByTemplates(s.connstr, templateIds)
+}
+
+func (s sqlSource) LoadFieldValuesTemplates(fieldIds, templateIds []uuid.UUID, c int) ([]data.FieldValueNode, error) {
+	return api.LoadFieldValuesTemplates(s.connstr, fieldIds, templateIds, c)
+}
+
+func (s sqlSource) LoadBlob(id uuid.UUID) (data.Blob, error) {
+	return api.LoadBlob(s.connstr, id)
+}
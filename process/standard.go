@@ -0,0 +1,93 @@
+package process
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jasontconnell/sitecore/data"
+)
+
+var SortOrderFieldId = uuid.Must(uuid.Parse("ba3f86a2-4a1c-4d78-b63d-91c2779c1b5e"))
+var OwnerFieldId = uuid.Must(uuid.Parse("52807595-0f8f-4b20-8d2a-cb71d28c6103"))
+var RevisionFieldId = uuid.Must(uuid.Parse("8cdc337e-a112-42fb-bbb4-4143751e123f"))
+var IconFieldId = uuid.Must(uuid.Parse("06d5295c-ed2f-4a54-9bf2-26228d113318"))
+var HiddenFieldId = uuid.Must(uuid.Parse("39c4902e-9960-4469-aeef-e878e9c8218f"))
+
+const sitecoreDateFormat string = "20060102T150405Z"
+
+// standard fields that are stored as field values, these are added to the field value load
+var standardFieldIds map[string]uuid.UUID = map[string]uuid.UUID{
+	DisplayNameOutputField: data.DisplayNameFieldId,
+	CreatedOutputField:     data.CreateDateFieldId,
+	CreatedByOutputField:   data.CreatedByFieldId,
+	UpdatedOutputField:     data.UpdateDateFieldId,
+	UpdatedByOutputField:   data.UpdatedByFieldId,
+	OwnerOutputField:       OwnerFieldId,
+	SortOrderOutputField:   SortOrderFieldId,
+	RevisionOutputField:    RevisionFieldId,
+	IconOutputField:        IconFieldId,
+	HiddenOutputField:      HiddenFieldId,
+}
+
+func isStandardField(name string) bool {
+	if _, ok := standardFieldIds[name]; ok {
+		return true
+	}
+	switch name {
+	case ItemNameOutputField, IdOutputField, PathOutputField, TemplateOutputField:
+		return true
+	}
+	return false
+}
+
+// returns the value of a : field and whether it has one
+func resolveStandardField(item data.ItemNode, name string, lang data.Language) (string, bool) {
+	switch name {
+	case ItemNameOutputField:
+		return item.GetName(), true
+	case IdOutputField:
+		return item.GetId().String(), true
+	case PathOutputField:
+		return item.GetPath(), true
+	case TemplateOutputField:
+		if t := item.GetTemplate(); t != nil {
+			return t.GetName(), true
+		}
+		return item.GetTemplateId().String(), true
+	}
+
+	fid, ok := standardFieldIds[name]
+	if !ok {
+		return "", false
+	}
+
+	fv := item.GetFieldValue(fid, lang)
+	if fv == nil && item.GetTemplate() != nil && item.GetTemplate().GetStandardValues() != nil {
+		fv = item.GetTemplate().GetStandardValues().GetFieldValue(fid, lang)
+	}
+
+	val := ""
+	if fv != nil {
+		val = fv.GetValue()
+	}
+
+	switch name {
+	case CreatedOutputField, UpdatedOutputField:
+		if val == "" {
+			// the items table has them when the field values don't
+			dt := item.GetCreated()
+			if name == UpdatedOutputField {
+				dt = item.GetUpdated()
+			}
+			if dt.IsZero() {
+				return "", false
+			}
+			return dt.UTC().Format(time.RFC3339), true
+		}
+		if dt, err := time.Parse(sitecoreDateFormat, val); err == nil {
+			return dt.Format(time.RFC3339), true
+		}
+	}
+
+	return val, val != ""
+}
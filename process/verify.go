@@ -0,0 +1,209 @@
+package process
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type VerifyResult struct {
+	Checked    int
+	Referenced int
+	Problems   []VerifyProblem
+}
+
+type VerifyProblem struct {
+	File   string
+	BlobId string
+	Reason string
+}
+
+// checks every blob in the manifests under dir against its file, and that
+// every blob referenced by the content files is in one of the manifests
+func Verify(dir string) (VerifyResult, error) {
+	result := VerifyResult{}
+	manifests := []string{}
+	contents := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if d.Name() == manifestFilename {
+			manifests = append(manifests, path)
+		} else if strings.EqualFold(filepath.Ext(path), ".xml") {
+			contents = append(contents, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("reading %s. %w", dir, err)
+	}
+
+	if len(manifests) == 0 {
+		return result, fmt.Errorf("no %s found in %s", manifestFilename, dir)
+	}
+
+	known := make(map[string]bool)
+	for _, mpath := range manifests {
+		buf, err := os.ReadFile(mpath)
+		if err != nil {
+			return result, fmt.Errorf("reading manifest %s. %w", mpath, err)
+		}
+		var mj ManifestJson
+		err = json.Unmarshal(buf, &mj)
+		if err != nil {
+			return result, fmt.Errorf("parsing manifest %s. %w", mpath, err)
+		}
+
+		for _, b := range mj.Blobs {
+			known[strings.ToLower(b.BlobId)] = true
+			result.Checked++
+			path := filepath.Join(filepath.Dir(mpath), filepath.FromSlash(b.File))
+			if reason := verifyBlobFile(path, b.Format, b.Length, b.Hash); reason != "" {
+				result.Problems = append(result.Problems, VerifyProblem{File: path, BlobId: b.BlobId, Reason: reason})
+			}
+
+			for _, r := range b.Renditions {
+				rpath := filepath.Join(filepath.Dir(mpath), filepath.FromSlash(r.File))
+				if reason := verifyBlobFile(rpath, b.Format, r.Length, r.Hash); reason != "" {
+					result.Problems = append(result.Problems, VerifyProblem{File: rpath, BlobId: b.BlobId, Reason: "rendition " + r.Name + " " + reason})
+				}
+			}
+		}
+	}
+
+	for _, cpath := range contents {
+		ids, err := getContentBlobIds(cpath)
+		if err != nil {
+			result.Problems = append(result.Problems, VerifyProblem{File: cpath, Reason: err.Error()})
+			continue
+		}
+		for _, id := range ids {
+			result.Referenced++
+			if !known[strings.ToLower(id)] {
+				result.Problems = append(result.Problems, VerifyProblem{File: cpath, BlobId: id, Reason: "referenced blob is not in a manifest"})
+			}
+		}
+	}
+
+	sort.SliceStable(result.Problems, func(i, j int) bool {
+		return result.Problems[i].File < result.Problems[j].File
+	})
+	return result, nil
+}
+
+// an empty reason means the file matches
+func verifyBlobFile(path, format string, expLength int, expHash string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "missing"
+		}
+		return err.Error()
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	var length int64
+	if format == RawBlobFormat {
+		length, err = io.Copy(h, f)
+	} else {
+		length, err = hashBlobXml(f, h)
+	}
+	if err != nil {
+		return err.Error()
+	}
+
+	if length != int64(expLength) {
+		return fmt.Sprintf("length is %d, expected %d", length, expLength)
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, expHash) {
+		return fmt.Sprintf("sha256 is %s, expected %s", sum, expHash)
+	}
+	return ""
+}
+
+func hashBlobXml(r io.Reader, h hash.Hash) (int64, error) {
+	var bxml BlobXml
+	err := xml.NewDecoder(r).Decode(&bxml)
+	if err != nil {
+		return 0, fmt.Errorf("parsing blob xml. %w", err)
+	}
+	if bxml.Data == nil {
+		return 0, fmt.Errorf("blob xml has no data")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(bxml.Data.Data))
+	if err != nil {
+		return 0, fmt.Errorf("decoding blob data. %w", err)
+	}
+	h.Write(data)
+	return int64(len(data)), nil
+}
+
+// blob ids from the blobrefs in a content file. anything that isn't content
+// (blob xml, sidecars, indexes) has a different root and is skipped
+func getContentBlobIds(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ids := []string{}
+	seen := make(map[string]bool)
+	dec := xml.NewDecoder(f)
+	root := true
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing content xml. %w", err)
+		}
+
+		se, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if root {
+			if se.Name.Local != "items" && se.Name.Local != "item" {
+				return nil, nil
+			}
+			root = false
+			continue
+		}
+		if se.Name.Local != "blob" {
+			continue
+		}
+		// filtered blobs are referenced but never written
+		id, skipped := "", false
+		for _, a := range se.Attr {
+			switch a.Name.Local {
+			case "blobid":
+				id = a.Value
+			case "skipped":
+				skipped = a.Value == "true"
+			}
+		}
+		if id != "" && !skipped && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
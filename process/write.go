@@ -3,8 +3,13 @@ package process
 import (
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 func WriteContent(groups []Group, settings WriteSettings) error {
@@ -16,9 +21,14 @@ func WriteContent(groups []Group, settings WriteSettings) error {
 
 	isxml := settings.ContentFormat == "xml"
 	for _, g := range groups {
-		if isxml {
+		if isxml && settings.Layout == FilesLayout {
+			err = writeContentFiles(fulldir, g, settings)
+			if err != nil {
+				return fmt.Errorf("writing item files for %s. %w", g.Name, err)
+			}
+		} else if isxml {
 			path := filepath.Join(fulldir, g.Name+"."+settings.ContentFormat)
-			err = writeContentXml(path, g)
+			err = writeContentXml(path, g, settings)
 			if err != nil {
 				return fmt.Errorf("writing file contents for %s, path: %s. %w", g.Name, path, err)
 			}
@@ -28,62 +38,123 @@ func WriteContent(groups []Group, settings WriteSettings) error {
 }
 
 func writeBlobXml(fullpath string, b BlobXml) error {
+	return writeFileAtomic(fullpath, func(w io.Writer) error {
+		enc := xml.NewEncoder(w)
+		enc.Indent(" ", " ")
+		return enc.Encode(b)
+	})
+}
+
+// writes to a temp file next to the file then renames it, so a run that's
+// interrupted never leaves half a file behind
+func writeFileAtomic(fullpath string, write func(w io.Writer) error) error {
+	f, err := os.CreateTemp(filepath.Dir(fullpath), "."+filepath.Base(fullpath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("opening temp file for write %s. %w", fullpath, err)
+	}
+	tmp := f.Name()
+
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("writing %s. %w", fullpath, err)
+	}
+
+	err = os.Rename(tmp, fullpath)
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("renaming temp file to %s. %w", fullpath, err)
+	}
+	return nil
+}
+
+func writeContentXml(fullpath string, g Group, settings WriteSettings) error {
+	list := g.Items
+	if settings.Layout == TreeLayout {
+		list = buildTree(g)
+	}
+
+	items := []ContentItem{}
+	for _, item := range list {
+		items = append(items, getContentItem(item, g.Name, settings))
+	}
+
 	f, err := os.OpenFile(fullpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("opening file for write %s. %w", fullpath, err)
 	}
 	defer f.Close()
+
 	enc := xml.NewEncoder(f)
 	enc.Indent(" ", " ")
 
-	return enc.Encode(b)
+	cxml := ContentsXml{ContentItems: items}
+	return enc.Encode(cxml)
 }
 
-func writeContentXml(fullpath string, g Group) error {
-	items := []ContentItem{}
+// each item goes in its own file under a folder for the group, with folders
+// following the item's parent path. the group file becomes an index of them
+func writeContentFiles(dir string, g Group, settings WriteSettings) error {
+	idx := IndexXml{Type: g.Name}
+	used := make(map[string]bool)
 	for _, item := range g.Items {
-		x := ContentItem{ID: item.ID, TypeName: g.Name, Name: item.Name, Path: item.Path}
-
-		xflds := []ContentField{}
-		for _, f := range item.Fields {
-			xf := ContentField{Name: f.Name}
-			if f.CData {
-				xf.Contents = f.Value
-			} else {
-				xf.Value = f.Value
-			}
-			for _, ref := range f.Refs {
-				xref := ContentItem{ID: ref.ID, Name: ref.Name, Path: ref.Path}
-				xrefflds := []ContentField{}
-				for _, xreffld := range ref.Fields {
-					if xreffld.Value != "" && xreffld.Name != "" {
-						xrefflds = append(xrefflds, ContentField{Name: xreffld.Name, Value: xreffld.Value})
-					}
-				}
-				if len(xrefflds) > 0 {
-					xref.Fields = &xrefflds
-				}
-				xf.Refs = append(xf.Refs, xref)
-			}
-			xflds = append(xflds, xf)
+		rel := getItemFilename(item, settings.FileNaming, settings.ContentFormat)
+		if used[strings.ToLower(rel)] {
+			// sitecore allows siblings with the same name
+			rel = strings.TrimSuffix(rel, "."+settings.ContentFormat) + "-" + item.ID + "." + settings.ContentFormat
 		}
+		used[strings.ToLower(rel)] = true
 
-		if len(xflds) > 0 {
-			x.Fields = &xflds
+		full := filepath.Join(dir, g.Name, rel)
+		err := os.MkdirAll(filepath.Dir(full), os.ModePerm)
+		if err != nil {
+			return fmt.Errorf("couldn't create dir structure %s. %w", filepath.Dir(full), err)
 		}
 
-		var bloblist []BlobRef
-		for _, b := range item.Blobs {
-			bref := BlobRef{ItemId: b.ItemId.String(), BlobId: b.BlobId.String(), Filename: b.Filename, Path: b.Path}
-			bloblist = append(bloblist, bref)
-		}
-		if len(bloblist) > 0 {
-			x.Blobs = &bloblist
+		err = writeXml(full, getContentItem(item, g.Name, settings))
+		if err != nil {
+			return err
 		}
 
-		items = append(items, x)
+		idx.Items = append(idx.Items, IndexItemXml{ID: item.ID, Name: item.Name, Path: item.Path, File: filepath.ToSlash(filepath.Join(g.Name, rel))})
 	}
 
+	return writeXml(filepath.Join(dir, g.Name+"."+settings.ContentFormat), idx)
+}
+
+func getItemFilename(item Item, naming, ext string) string {
+	parts := []string{}
+	for _, p := range strings.Split(strings.Trim(item.Path, "/"), "/") {
+		parts = append(parts, sanitizeFilename(p))
+	}
+	if len(parts) > 0 {
+		parts = parts[:len(parts)-1]
+	}
+
+	name := item.ID
+	if naming == PathFileNaming {
+		name = sanitizeFilename(item.Name)
+	}
+	return filepath.Join(append(parts, name+"."+ext)...)
+}
+
+var unsafeFilenameReg *regexp.Regexp = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func sanitizeFilename(s string) string {
+	s = strings.Trim(unsafeFilenameReg.ReplaceAllString(s, "-"), ".-")
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+func writeXml(fullpath string, v interface{}) error {
 	f, err := os.OpenFile(fullpath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return fmt.Errorf("opening file for write %s. %w", fullpath, err)
@@ -92,7 +163,187 @@ func writeContentXml(fullpath string, g Group) error {
 
 	enc := xml.NewEncoder(f)
 	enc.Indent(" ", " ")
+	return enc.Encode(v)
+}
 
-	cxml := ContentsXml{ContentItems: items}
-	return enc.Encode(cxml)
+func getRefContentItem(ref Item) ContentItem {
+	xref := ContentItem{ID: ref.ID, Name: ref.Name, Path: ref.Path}
+	xrefflds := []ContentField{}
+	for _, xreffld := range ref.Fields {
+		if xreffld.Value != "" && xreffld.Name != "" {
+			xrefflds = append(xrefflds, ContentField{Name: xreffld.Name, Value: xreffld.Value})
+		}
+	}
+	if len(xrefflds) > 0 {
+		xref.Fields = &xrefflds
+	}
+	return xref
+}
+
+func getLinkXml(link Link) *LinkXml {
+	lx := &LinkXml{
+		Type:        link.Type,
+		Url:         link.Url,
+		Text:        link.Text,
+		Title:       link.Title,
+		Target:      link.Target,
+		Anchor:      link.Anchor,
+		QueryString: link.QueryString,
+		Class:       link.Class,
+	}
+	if link.Item != nil {
+		xitem := getRefContentItem(*link.Item)
+		lx.Item = &xitem
+	}
+	return lx
+}
+
+func getLayoutXml(layout Layout) *LayoutXml {
+	lx := &LayoutXml{}
+	for _, d := range layout.Devices {
+		dx := LayoutDeviceXml{ID: d.ID, Name: d.Name, LayoutId: d.LayoutId, LayoutName: d.LayoutName}
+		for _, r := range d.Renderings {
+			rx := LayoutRenderingXml{ID: r.ID, Name: r.Name, Uid: r.Uid, Placeholder: r.Placeholder}
+			if r.DataSource != nil {
+				rx.DataSource = &DataSourceXml{Value: r.DataSource.Value, ID: r.DataSource.ID, Path: r.DataSource.Path}
+			}
+			var params []ParamXml
+			for _, p := range r.Parameters {
+				params = append(params, ParamXml{Name: p.Name, Value: p.Value})
+			}
+			if len(params) > 0 {
+				rx.Parameters = &params
+			}
+			dx.Renderings = append(dx.Renderings, rx)
+		}
+		lx.Devices = append(lx.Devices, dx)
+	}
+	return lx
+}
+
+func getContentItem(item Item, typeName string, settings WriteSettings) ContentItem {
+	x := ContentItem{ID: item.ID, TypeName: typeName, Name: item.Name, Path: item.Path, ParentId: item.ParentId, SortOrder: item.SortOrder, Placeholder: item.Placeholder}
+	if item.Placeholder {
+		x.TypeName = ""
+	}
+
+	xflds := []ContentField{}
+	for _, f := range item.Fields {
+		xf := ContentField{Name: f.Name}
+		if f.CData {
+			xf.Contents = f.Value
+		} else {
+			xf.Value = f.Value
+		}
+		for _, ref := range f.Refs {
+			xf.Refs = append(xf.Refs, getRefContentItem(ref))
+		}
+		if f.Link != nil {
+			xf.Link = getLinkXml(*f.Link)
+		}
+		if f.Image != nil {
+			img := f.Image
+			xf.Image = &ImageXml{Alt: img.Alt, AltSource: img.AltSource, Width: img.Width, Height: img.Height, HSpace: img.HSpace, VSpace: img.VSpace, Class: img.Class}
+		}
+		if f.Layout != nil {
+			xf.Layout = getLayoutXml(*f.Layout)
+		}
+		xflds = append(xflds, xf)
+	}
+
+	if len(xflds) > 0 {
+		x.Fields = &xflds
+	}
+
+	var bloblist []BlobRef
+	for _, b := range item.Blobs {
+		bref := BlobRef{ItemId: b.ItemId.String(), BlobId: b.BlobId.String(), Filename: b.Filename, Path: b.Path, Hash: settings.BlobHashes[b.BlobId], Language: b.Language}
+		if reason, ok := settings.BlobSkipped[b.BlobId]; ok && b.SkipReason == "" {
+			b.SkipReason = reason
+		}
+		if b.SkipReason != "" {
+			bref.Skipped, bref.Reason = true, b.SkipReason
+		}
+		bloblist = append(bloblist, bref)
+	}
+	if len(bloblist) > 0 {
+		x.Blobs = &bloblist
+	}
+
+	var reflist []ItemRefXml
+	for _, r := range item.ItemRefs {
+		reflist = append(reflist, ItemRefXml{ItemId: r.ItemId.String(), Name: r.Name, Path: r.Path})
+	}
+	if len(reflist) > 0 {
+		x.ItemRefs = &reflist
+	}
+
+	var unresolved []UnresolvedXml
+	for _, u := range item.Unresolved {
+		unresolved = append(unresolved, UnresolvedXml{Field: u.Field, Value: u.Value, Reason: u.Reason})
+	}
+	if len(unresolved) > 0 {
+		x.Unresolved = &unresolved
+	}
+
+	var dslist []ContentItem
+	for _, ds := range item.Datasources {
+		dslist = append(dslist, getContentItem(ds, ds.TypeName, settings))
+	}
+	if len(dslist) > 0 {
+		x.Datasources = &dslist
+	}
+
+	var children []ContentItem
+	for _, c := range item.Children {
+		children = append(children, getContentItem(c, typeName, settings))
+	}
+	if len(children) > 0 {
+		x.Children = &children
+	}
+
+	return x
+}
+
+// nests items and placeholder folders by parent id, anything whose parent
+// isn't in the group is a root
+func buildTree(g Group) []Item {
+	all := append(append([]Item{}, g.Items...), g.Folders...)
+	ids := make(map[string]bool)
+	for _, item := range all {
+		ids[item.ID] = true
+	}
+
+	children := make(map[string][]Item)
+	roots := []Item{}
+	for _, item := range all {
+		if ids[item.ParentId] {
+			children[item.ParentId] = append(children[item.ParentId], item)
+		} else {
+			roots = append(roots, item)
+		}
+	}
+
+	var nest func(list []Item) []Item
+	nest = func(list []Item) []Item {
+		sortItems(list)
+		for i := range list {
+			list[i].Children = nest(children[list[i].ID])
+		}
+		return list
+	}
+
+	return nest(roots)
+}
+
+// sitecore sorts by sort order then name
+func sortItems(list []Item) {
+	sort.SliceStable(list, func(i, j int) bool {
+		si, _ := strconv.Atoi(list[i].SortOrder)
+		sj, _ := strconv.Atoi(list[j].SortOrder)
+		if si != sj {
+			return si < sj
+		}
+		return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name)
+	})
 }
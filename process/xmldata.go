@@ -3,14 +3,32 @@ package process
 import "encoding/xml"
 
 type BlobXml struct {
-	XMLName  xml.Name       `xml:"blob"`
-	ItemId   string         `xml:"id,attr"`
-	BlobId   string         `xml:"blobId,attr"`
-	Filename string         `xml:"filename,attr"`
-	Path     string         `xml:"path,attr"`
-	Length   int            `xml:"length,attr"`
-	Fields   []BlobFieldXml `xml:"fields>field,omitempty"`
-	Data     BlobDataXml    `xml:"data"`
+	XMLName     xml.Name           `xml:"blob" json:"-"`
+	ItemId      string             `xml:"id,attr" json:"id"`
+	BlobId      string             `xml:"blobId,attr" json:"blobId"`
+	Filename    string             `xml:"filename,attr" json:"filename"`
+	Path        string             `xml:"path,attr" json:"path"`
+	Length      int                `xml:"length,attr" json:"length"`
+	Language    string             `xml:"language,attr,omitempty" json:"language,omitempty"`
+	FilePath    string             `xml:"filePath,attr,omitempty" json:"filePath,omitempty"`
+	MimeType    string             `xml:"mimeType,attr,omitempty" json:"mimeType,omitempty"`
+	ContentType string             `xml:"contentType,attr,omitempty" json:"contentType,omitempty"`
+	Width       int                `xml:"width,attr,omitempty" json:"width,omitempty"`
+	Height      int                `xml:"height,attr,omitempty" json:"height,omitempty"`
+	Mismatch    string             `xml:"mismatch,attr,omitempty" json:"mismatch,omitempty"`
+	Fields      []BlobFieldXml     `xml:"fields>field,omitempty" json:"fields,omitempty"`
+	Renditions  []BlobRenditionXml `xml:"renditions>rendition,omitempty" json:"renditions,omitempty"`
+	Data        *BlobDataXml       `xml:"data,omitempty" json:"-"`
+}
+
+type BlobRenditionXml struct {
+	XMLName  xml.Name `xml:"rendition" json:"-"`
+	Name     string   `xml:"name,attr" json:"name"`
+	File     string   `xml:"file,attr" json:"file"`
+	Width    int      `xml:"width,attr" json:"width"`
+	Height   int      `xml:"height,attr" json:"height"`
+	Length   int      `xml:"length,attr" json:"length"`
+	MimeType string   `xml:"mimeType,attr" json:"mimeType"`
 }
 
 type BlobDataXml struct {
@@ -19,9 +37,9 @@ type BlobDataXml struct {
 }
 
 type BlobFieldXml struct {
-	XMLName xml.Name `xml:"field"`
-	Name    string   `xml:"name,attr"`
-	Value   string   `xml:"value,attr"`
+	XMLName xml.Name `xml:"field" json:"-"`
+	Name    string   `xml:"name,attr" json:"name"`
+	Value   string   `xml:"value,attr" json:"value"`
 }
 
 type ContentsXml struct {
@@ -29,14 +47,35 @@ type ContentsXml struct {
 	ContentItems []ContentItem `xml:"item"`
 }
 
+type IndexXml struct {
+	XMLName xml.Name       `xml:"index"`
+	Type    string         `xml:"type,attr"`
+	Items   []IndexItemXml `xml:"item"`
+}
+
+type IndexItemXml struct {
+	XMLName xml.Name `xml:"item"`
+	ID      string   `xml:"id,attr"`
+	Name    string   `xml:"name,attr"`
+	Path    string   `xml:"path,attr"`
+	File    string   `xml:"file,attr"`
+}
+
 type ContentItem struct {
-	XMLName  xml.Name        `xml:"item"`
-	ID       string          `xml:"id,attr"`
-	TypeName string          `xml:"type,attr,omitempty"`
-	Name     string          `xml:"name,attr,omitempty"`
-	Path     string          `xml:"path,attr"`
-	Fields   *[]ContentField `xml:"fields>field"`
-	Blobs    *[]BlobRef      `xml:"blobrefs>blob,omitempty"`
+	XMLName     xml.Name         `xml:"item"`
+	ID          string           `xml:"id,attr"`
+	TypeName    string           `xml:"type,attr,omitempty"`
+	Name        string           `xml:"name,attr,omitempty"`
+	Path        string           `xml:"path,attr"`
+	ParentId    string           `xml:"parentid,attr,omitempty"`
+	SortOrder   string           `xml:"sortorder,attr,omitempty"`
+	Placeholder bool             `xml:"placeholder,attr,omitempty"`
+	Fields      *[]ContentField  `xml:"fields>field"`
+	Blobs       *[]BlobRef       `xml:"blobrefs>blob,omitempty"`
+	ItemRefs    *[]ItemRefXml    `xml:"itemrefs>item,omitempty"`
+	Unresolved  *[]UnresolvedXml `xml:"unresolved>ref,omitempty"`
+	Datasources *[]ContentItem   `xml:"datasources>item,omitempty"`
+	Children    *[]ContentItem   `xml:"children>item,omitempty"`
 }
 
 type ContentField struct {
@@ -45,6 +84,64 @@ type ContentField struct {
 	Value    string        `xml:"value,attr,omitempty"`
 	Contents string        `xml:",cdata"`
 	Refs     []ContentItem `xml:"refs,omitempty"`
+	Link     *LinkXml      `xml:"link,omitempty"`
+	Image    *ImageXml     `xml:"image,omitempty"`
+	Layout   *LayoutXml    `xml:"layout,omitempty"`
+}
+
+type LayoutXml struct {
+	XMLName xml.Name          `xml:"layout"`
+	Devices []LayoutDeviceXml `xml:"device"`
+}
+
+type LayoutDeviceXml struct {
+	XMLName    xml.Name             `xml:"device"`
+	ID         string               `xml:"id,attr"`
+	Name       string               `xml:"name,attr,omitempty"`
+	LayoutId   string               `xml:"layoutid,attr,omitempty"`
+	LayoutName string               `xml:"layoutname,attr,omitempty"`
+	Renderings []LayoutRenderingXml `xml:"rendering"`
+}
+
+type LayoutRenderingXml struct {
+	XMLName     xml.Name       `xml:"rendering"`
+	ID          string         `xml:"id,attr"`
+	Name        string         `xml:"name,attr,omitempty"`
+	Uid         string         `xml:"uid,attr,omitempty"`
+	Placeholder string         `xml:"placeholder,attr"`
+	DataSource  *DataSourceXml `xml:"datasource,omitempty"`
+	Parameters  *[]ParamXml    `xml:"params>param,omitempty"`
+}
+
+type DataSourceXml struct {
+	XMLName xml.Name `xml:"datasource"`
+	Value   string   `xml:"value,attr"`
+	ID      string   `xml:"id,attr,omitempty"`
+	Path    string   `xml:"path,attr,omitempty"`
+}
+
+type ImageXml struct {
+	XMLName   xml.Name `xml:"image"`
+	Alt       string   `xml:"alt,attr,omitempty"`
+	AltSource string   `xml:"altsource,attr,omitempty"`
+	Width     string   `xml:"width,attr,omitempty"`
+	Height    string   `xml:"height,attr,omitempty"`
+	HSpace    string   `xml:"hspace,attr,omitempty"`
+	VSpace    string   `xml:"vspace,attr,omitempty"`
+	Class     string   `xml:"class,attr,omitempty"`
+}
+
+type LinkXml struct {
+	XMLName     xml.Name     `xml:"link"`
+	Type        string       `xml:"type,attr"`
+	Url         string       `xml:"url,attr,omitempty"`
+	Text        string       `xml:"text,attr,omitempty"`
+	Title       string       `xml:"title,attr,omitempty"`
+	Target      string       `xml:"target,attr,omitempty"`
+	Anchor      string       `xml:"anchor,attr,omitempty"`
+	QueryString string       `xml:"querystring,attr,omitempty"`
+	Class       string       `xml:"class,attr,omitempty"`
+	Item        *ContentItem `xml:"item,omitempty"`
 }
 
 type BlobRef struct {
@@ -53,4 +150,28 @@ type BlobRef struct {
 	BlobId   string   `xml:"blobid,attr"`
 	Filename string   `xml:"filename,attr"`
 	Path     string   `xml:"path,attr"`
+	Hash     string   `xml:"hash,attr,omitempty"`
+	Language string   `xml:"language,attr,omitempty"`
+	Skipped  bool     `xml:"skipped,attr,omitempty"`
+	Reason   string   `xml:"reason,attr,omitempty"`
+}
+
+type ItemRefXml struct {
+	XMLName xml.Name `xml:"item"`
+	ItemId  string   `xml:"itemid,attr"`
+	Name    string   `xml:"name,attr,omitempty"`
+	Path    string   `xml:"path,attr"`
+}
+
+type UnresolvedXml struct {
+	XMLName xml.Name `xml:"ref"`
+	Field   string   `xml:"field,attr"`
+	Value   string   `xml:"value,attr"`
+	Reason  string   `xml:"reason,attr"`
+}
+
+type ParamXml struct {
+	XMLName xml.Name `xml:"param"`
+	Name    string   `xml:"name,attr"`
+	Value   string   `xml:"value,attr"`
 }